@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"lazywhisper/config"
+	"os"
+	"path/filepath"
+)
+
+// runExportArchive writes every transcription into a .tar.gz at outPath
+func runExportArchive(outPath string) int {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	transcriptions, err := openStore(settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// Write next to the destination and rename, so a failed export never leaves a truncated archive
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), filepath.Base(outPath)+".*.tmp")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to create %s: %v\n", outPath, err)
+		return 1
+	}
+	defer os.Remove(tmp.Name())
+
+	manifest, err := transcriptions.ExportArchive(tmp)
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write %s: %w", outPath, closeErr)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := os.Rename(tmp.Name(), outPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write %s: %v\n", outPath, err)
+		return 1
+	}
+
+	files := 0
+	for _, entry := range manifest.Entries {
+		files += len(entry.Files)
+	}
+	fmt.Printf("Exported %d transcriptions (%d files) to %s\n", len(manifest.Entries), files, outPath)
+	if settings.Encryption.Enabled {
+		fmt.Fprintln(os.Stderr, "Note: the archive is not encrypted")
+	}
+	return 0
+}
+
+// runImport merges an archive written by `export --archive` into the store
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	archive := fs.String("archive", "", "archive written by lazywhisper export --archive")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *archive == "" || fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Expected --archive <file.tar.gz>\n\n%s", usage)
+		return 2
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	transcriptions, err := openStore(settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	file, err := os.Open(*archive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to open archive: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	result, err := transcriptions.ImportArchive(file)
+	if result != nil {
+		for _, id := range result.Imported {
+			fmt.Printf("imported  %s\n", id)
+		}
+		for _, id := range result.Conflicts {
+			fmt.Printf("conflict  %s (kept the local copy)\n", id)
+		}
+		fmt.Printf("Imported %d, %d already here, %d conflicts\n", len(result.Imported), len(result.Identical), len(result.Conflicts))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(result.Conflicts) > 0 {
+		return 1
+	}
+	return 0
+}
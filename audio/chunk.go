@@ -0,0 +1,121 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxUploadBytes keeps us safely under the API's 25 MB upload limit
+	maxUploadBytes = 24 * 1024 * 1024
+	// chunkSeconds is the length of each piece when splitting a long recording.
+	// Ten minutes of 16 kHz mono wav is roughly 19 MB.
+	chunkSeconds = 600
+)
+
+// transcribeChunked splits a recording that is too large to upload, transcribes
+// each piece and merges the results into a single response
+func (t *Transcriber) transcribeChunked(audioFile string, opts TranscribeOptions) (*TranscriptionResponse, error) {
+	chunks, cleanup, err := splitAudio(audioFile)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	var parts []*TranscriptionResponse
+	for i, chunk := range chunks {
+		part, err := t.request(chunk, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transcribe chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+		parts = append(parts, part)
+	}
+
+	return mergeChunks(parts), nil
+}
+
+// splitAudio cuts audioFile into chunkSeconds long 16 kHz mono wav files in a
+// temporary directory. The returned cleanup func removes them.
+func splitAudio(audioFile string) ([]string, func(), error) {
+	dir, err := os.MkdirTemp("", "lazywhisper-chunks-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+
+	cmd := exec.Command("ffmpeg",
+		"-i", audioFile,
+		"-ac", "1",
+		"-ar", "16000",
+		"-f", "segment",
+		"-segment_time", fmt.Sprintf("%d", chunkSeconds),
+		"-c:a", "pcm_s16le",
+		filepath.Join(dir, "chunk-%03d.wav"),
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to split audio: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	chunks, err := filepath.Glob(filepath.Join(dir, "chunk-*.wav"))
+	if err != nil || len(chunks) == 0 {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to split audio: no chunks written")
+	}
+	sort.Strings(chunks)
+
+	return chunks, cleanup, nil
+}
+
+// mergeChunks joins per-chunk responses, shifting every timestamp by the total
+// duration of the chunks before it so segments and words line up with the
+// original recording. The segment muxer cuts on packet boundaries, so the
+// measured duration of each chunk is used rather than chunkSeconds.
+func mergeChunks(parts []*TranscriptionResponse) *TranscriptionResponse {
+	merged := &TranscriptionResponse{}
+	var texts []string
+	offset := 0.0
+
+	for i, part := range parts {
+		if merged.Language == "" {
+			merged.Language = part.Language
+		}
+		if text := strings.TrimSpace(part.Text); text != "" {
+			texts = append(texts, text)
+		}
+
+		for _, segment := range part.Segments {
+			segment.ID = len(merged.Segments)
+			segment.Start += offset
+			segment.End += offset
+			merged.Segments = append(merged.Segments, segment)
+		}
+		for _, word := range part.Words {
+			word.Start += offset
+			word.End += offset
+			merged.Words = append(merged.Words, word)
+		}
+
+		// Without a measured duration, fall back to the length ffmpeg was asked
+		// for. The last chunk is shorter, so it ends with its last segment.
+		duration := part.Duration
+		if duration <= 0 {
+			duration = chunkSeconds
+			if i == len(parts)-1 {
+				duration = 0
+				if n := len(part.Segments); n > 0 {
+					duration = part.Segments[n-1].End
+				}
+			}
+		}
+		offset += duration
+	}
+
+	merged.Text = strings.Join(texts, " ")
+	merged.Duration = offset
+	return merged
+}
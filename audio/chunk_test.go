@@ -0,0 +1,78 @@
+package audio
+
+import "testing"
+
+func TestMergeChunksShiftsTimestamps(t *testing.T) {
+	parts := []*TranscriptionResponse{
+		{
+			Language: "english",
+			Text:     " first chunk ",
+			Duration: 600.5,
+			Segments: []Segment{{ID: 0, Start: 0, End: 2, Text: "first"}, {ID: 1, Start: 598, End: 600.5, Text: "chunk"}},
+			Words:    []Word{{Word: "first", Start: 0, End: 1}},
+		},
+		{
+			Language: "english",
+			Text:     "second chunk",
+			Duration: 599.25,
+			Segments: []Segment{{ID: 0, Start: 1, End: 3, Text: "second chunk"}},
+			Words:    []Word{{Word: "second", Start: 1, End: 2}},
+		},
+	}
+
+	merged := mergeChunks(parts)
+	if merged.Text != "first chunk second chunk" || merged.Language != "english" {
+		t.Errorf("merged text = %q (%s)", merged.Text, merged.Language)
+	}
+	if merged.Duration != 1199.75 {
+		t.Errorf("duration = %v, want 1199.75", merged.Duration)
+	}
+	wantSegments := []Segment{
+		{ID: 0, Start: 0, End: 2, Text: "first"},
+		{ID: 1, Start: 598, End: 600.5, Text: "chunk"},
+		{ID: 2, Start: 601.5, End: 603.5, Text: "second chunk"},
+	}
+	if len(merged.Segments) != len(wantSegments) {
+		t.Fatalf("segments = %+v, want %+v", merged.Segments, wantSegments)
+	}
+	for i, want := range wantSegments {
+		if got := merged.Segments[i]; got != want {
+			t.Errorf("segment %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if len(merged.Words) != 2 || merged.Words[1].Start != 601.5 || merged.Words[1].End != 602.5 {
+		t.Errorf("words = %+v, want the second chunk's shifted by 600.5s", merged.Words)
+	}
+}
+
+func TestMergeChunksWithoutDurations(t *testing.T) {
+	tests := []struct {
+		name         string
+		durations    []float64
+		wantStart    float64
+		wantDuration float64
+	}{
+		{"measured", []float64{590, 42.5}, 591, 632.5},
+		{"first chunk unmeasured", []float64{0, 42.5}, chunkSeconds + 1, chunkSeconds + 42.5},
+		{"last chunk unmeasured", []float64{590, 0}, 591, 593},
+		{"negative duration", []float64{-1, -1}, chunkSeconds + 1, chunkSeconds + 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var parts []*TranscriptionResponse
+			for _, duration := range tt.durations {
+				parts = append(parts, &TranscriptionResponse{
+					Duration: duration,
+					Segments: []Segment{{Start: 1, End: 3, Text: "hello"}},
+				})
+			}
+			merged := mergeChunks(parts)
+			if got := merged.Segments[1].Start; got != tt.wantStart {
+				t.Errorf("second chunk starts at %v, want %v", got, tt.wantStart)
+			}
+			if merged.Duration != tt.wantDuration {
+				t.Errorf("duration = %v, want %v", merged.Duration, tt.wantDuration)
+			}
+		})
+	}
+}
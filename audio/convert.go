@@ -0,0 +1,51 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RecordingPath returns the first timestamped recording name at or after t
+// that isn't taken in dir, along with its id
+func RecordingPath(dir string, t time.Time) (string, string) {
+	for {
+		id := t.Format("2006-01-02-15-04-05")
+		path := filepath.Join(dir, id+".wav")
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return id, path
+		}
+		t = t.Add(time.Second)
+	}
+}
+
+// ConvertToWav converts source into a 16 kHz mono wav file at dest. The file
+// only appears at dest once the conversion succeeded.
+func ConvertToWav(source, dest string) error {
+	partFile := dest + ".part"
+	cmd := exec.Command("ffmpeg",
+		"-i", source,
+		"-ac", "1",
+		"-ar", "16000",
+		"-f", "wav",
+		"-y",
+		partFile,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		_ = os.Remove(partFile)
+		return fmt.Errorf("failed to convert %s: %w: %s", filepath.Base(source), err, lastLine(output))
+	}
+	if err := os.Rename(partFile, dest); err != nil {
+		_ = os.Remove(partFile)
+		return fmt.Errorf("failed to convert %s: %w", filepath.Base(source), err)
+	}
+	return nil
+}
+
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return lines[len(lines)-1]
+}
@@ -0,0 +1,216 @@
+package audio
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Player plays recordings with an external command such as ffplay. Commands
+// like that can't be paused or seeked from outside, so pausing stops the
+// process and resuming or seeking restarts it from the remembered position.
+type Player struct {
+	command []string
+	prepare PrepareFunc
+
+	mu   sync.Mutex
+	cmd  *exec.Cmd
+	file string
+	// path is what the command plays, which differs from file when prepare made a copy
+	path     string
+	cleanup  func()
+	duration float64
+	// offset is the position the current process started from, or the paused position
+	offset  float64
+	started time.Time
+	playing bool
+}
+
+// PrepareFunc returns a path the playback command can read file from and a
+// function removing anything it created, e.g. a decrypted temporary copy
+type PrepareFunc func(file string) (string, func(), error)
+
+// NewPlayer creates a player running command, where {file} is replaced with the
+// file to play and {start} with the position in seconds to start from
+func NewPlayer(command []string) *Player {
+	return &Player{command: command}
+}
+
+// SetPrepare sets how files are made playable before the command starts
+func (p *Player) SetPrepare(prepare PrepareFunc) {
+	p.prepare = prepare
+}
+
+// Play starts file from the beginning, stopping anything already playing.
+// duration is used to clamp seeking and may be zero when unknown.
+func (p *Player) Play(file string, duration float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stop()
+	p.unload()
+	path, cleanup := file, func() {}
+	if p.prepare != nil {
+		var err error
+		if path, cleanup, err = p.prepare(file); err != nil {
+			return err
+		}
+	}
+	p.file = file
+	p.path = path
+	p.cleanup = cleanup
+	p.duration = duration
+	p.offset = 0
+	return p.start()
+}
+
+// TogglePause pauses playback, or resumes it from where it was paused
+func (p *Player) TogglePause() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.file == "" {
+		return nil
+	}
+	if p.playing {
+		p.stop() // Remembers the position
+		return nil
+	}
+	// Start over once the end was reached
+	if p.duration > 0 && p.offset >= p.duration {
+		p.offset = 0
+	}
+	return p.start()
+}
+
+// Seek moves the position by delta seconds, keeping the player paused or playing
+func (p *Player) Seek(delta float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.file == "" {
+		return nil
+	}
+	position := max(p.position()+delta, 0)
+	if p.duration > 0 {
+		position = min(position, p.duration)
+	}
+
+	if !p.playing {
+		p.offset = position
+		return nil
+	}
+	p.stop()
+	p.offset = position
+	return p.start()
+}
+
+// Position returns the current playback position in seconds
+func (p *Player) Position() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position()
+}
+
+// Duration returns the length of the loaded file in seconds, or zero when unknown
+func (p *Player) Duration() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.duration
+}
+
+// File returns the loaded file, or an empty string when nothing is loaded
+func (p *Player) File() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.file
+}
+
+// Playing reports whether audio is currently playing
+func (p *Player) Playing() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.playing
+}
+
+// Stop ends playback and unloads the file
+func (p *Player) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stop()
+	p.unload()
+	p.offset = 0
+	p.duration = 0
+}
+
+// unload forgets the loaded file, removing any copy made to play it. The caller holds the lock.
+func (p *Player) unload() {
+	if p.cleanup != nil {
+		p.cleanup()
+	}
+	p.file = ""
+	p.path = ""
+	p.cleanup = nil
+}
+
+func (p *Player) position() float64 {
+	position := p.offset
+	if p.playing {
+		position += time.Since(p.started).Seconds()
+	}
+	if p.duration > 0 {
+		position = min(position, p.duration)
+	}
+	return position
+}
+
+func (p *Player) start() error {
+	if len(p.command) == 0 {
+		return fmt.Errorf("no playback command configured")
+	}
+
+	args := make([]string, len(p.command))
+	for i, arg := range p.command {
+		arg = strings.ReplaceAll(arg, "{file}", p.path)
+		arg = strings.ReplaceAll(arg, "{start}", strconv.FormatFloat(p.offset, 'f', 2, 64))
+		args[i] = arg
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", args[0], err)
+	}
+	p.cmd = cmd
+	p.started = time.Now()
+	p.playing = true
+
+	// Notice when the file finishes playing on its own
+	go func() {
+		_ = cmd.Wait()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.cmd == cmd {
+			p.offset = p.position()
+			p.playing = false
+			p.cmd = nil
+		}
+	}()
+	return nil
+}
+
+// stop kills the running process, if any. The caller holds the lock.
+func (p *Player) stop() {
+	if p.cmd == nil {
+		return
+	}
+	if p.playing {
+		p.offset = p.position()
+	}
+	_ = p.cmd.Process.Kill()
+	p.cmd = nil
+	p.playing = false
+}
@@ -16,7 +16,10 @@ type Recorder struct {
 	outputFile  string
 	isRecording bool
 	appDataDir  string
-	timer       *time.Timer
+	// outputDir overrides where recordings are written, e.g. a private temp
+	// directory while encryption is on
+	outputDir string
+	timer     *time.Timer
 }
 
 func NewRecorder() *Recorder {
@@ -32,6 +35,11 @@ func NewRecorder() *Recorder {
 	}
 }
 
+// SetOutputDir writes new recordings to dir instead of the recordings directory
+func (r *Recorder) SetOutputDir(dir string) {
+	r.outputDir = dir
+}
+
 func (r *Recorder) StartRecording() error {
 	if r.isRecording {
 		return fmt.Errorf("recording is already in progress")
@@ -39,7 +47,11 @@ func (r *Recorder) StartRecording() error {
 
 	// Generate output filename with timestamp
 	timestamp := time.Now().Format("2006-01-02-15-04-05")
-	r.outputFile = filepath.Join(r.appDataDir, config.RecordingsDir, fmt.Sprintf("%s.wav", timestamp))
+	outputDir := filepath.Join(r.appDataDir, config.RecordingsDir)
+	if r.outputDir != "" {
+		outputDir = r.outputDir
+	}
+	r.outputFile = filepath.Join(outputDir, fmt.Sprintf("%s.wav", timestamp))
 
 	// Start ffmpeg process with stderr piped to null to avoid noise
 	r.cmd = exec.Command("ffmpeg",
@@ -137,10 +149,11 @@ func (r *Recorder) killOrphanedFFmpegProcesses() {
 		return
 	}
 
-	// Parse the output to find ffmpeg processes recording to .open_whisper
+	// Parse the output to find ffmpeg processes recording to .open_whisper. Only
+	// capture processes are matched so conversions started by the watcher survive.
 	lines := strings.Split(string(output), "\n")
 	for _, line := range lines {
-		if strings.Contains(line, "ffmpeg") && strings.Contains(line, ".open_whisper") {
+		if strings.Contains(line, "ffmpeg") && strings.Contains(line, "avfoundation") && strings.Contains(line, ".open_whisper") {
 			fields := strings.Fields(line)
 			if len(fields) < 2 {
 				continue
@@ -5,40 +5,167 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"lazywhisper/config"
-	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type Transcriber struct {
-	apiKey     string
-	appDataDir string
+	apiKey              string
+	store               Store
+	prompt              string
+	processor           TextProcessor
+	redactor            Redactor
+	deleteRedactedAudio bool
 }
 
+// Store persists finished transcriptions
+type Store interface {
+	Save(audioFile string, result *TranscriptionResponse) error
+}
+
+// Redactor masks sensitive text and reports how many items it masked
+type Redactor interface {
+	Redact(text string) (string, int)
+}
+
+// TextProcessor rewrites transcribed text before it is saved. language is the
+// requested ISO-639-1 code, or the language the API detected when none was requested.
+type TextProcessor interface {
+	Apply(text, language string) string
+}
+
+const (
+	// Model is the transcription model sent with every request
+	Model = "whisper-1"
+
+	transcriptionsURL = "https://api.openai.com/v1/audio/transcriptions"
+	translationsURL   = "https://api.openai.com/v1/audio/translations"
+)
+
+// TranscribeOptions are the per request settings for Transcribe
+type TranscribeOptions struct {
+	// Language is the ISO-639-1 code of the spoken language. Empty lets the API detect it.
+	Language string
+	// Translate sends the audio to the translations endpoint, which always returns English
+	Translate bool
+}
+
+// TranscriptionResponse is the verbose_json response from the transcriptions API.
+// It is also persisted as a sidecar .json next to each transcription .txt.
 type TranscriptionResponse struct {
-	Text string `json:"text"`
+	Text     string    `json:"text"`
+	Model    string    `json:"model,omitempty"`
+	Language string    `json:"language,omitempty"`
+	Duration float64   `json:"duration,omitempty"`
+	Segments []Segment `json:"segments,omitempty"`
+	Words    []Word    `json:"words,omitempty"`
+	// Translation is set when the text was translated to English rather than transcribed
+	Translation bool `json:"translation,omitempty"`
+	// RawText is the text as returned by the API, kept when post-processing changed it
+	RawText string `json:"raw_text,omitempty"`
+	// Redactions is the number of items masked in Text
+	Redactions int `json:"redactions,omitempty"`
+}
+
+// Segment is a phrase level chunk of the transcription with timings in seconds
+type Segment struct {
+	ID    int     `json:"id"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// Word is a single word with timings in seconds
+type Word struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// NewTranscriber creates a transcriber that saves its results to store
+func NewTranscriber(apiKey string, store Store) *Transcriber {
+	return &Transcriber{
+		apiKey: apiKey,
+		store:  store,
+	}
+}
+
+// SetVocabulary sends words as the prompt with every request so the API
+// prefers those spellings
+func (t *Transcriber) SetVocabulary(words []string) {
+	t.prompt = strings.Join(words, ", ")
+}
+
+// SetProcessor sets the post-processing applied to text before it is saved
+func (t *Transcriber) SetProcessor(processor TextProcessor) {
+	t.processor = processor
+}
+
+// SetRedactor masks sensitive data in everything saved for a transcription.
+// With deleteAudio set, recordings that had anything redacted are removed.
+func (t *Transcriber) SetRedactor(redactor Redactor, deleteAudio bool) {
+	t.redactor = redactor
+	t.deleteRedactedAudio = deleteAudio
 }
 
-func NewTranscriber(apiKey string) *Transcriber {
-	// Get app data directory
-	appDataDir, err := config.GetAppDataDir()
+func (t *Transcriber) Transcribe(audioFile string, opts TranscribeOptions) (*TranscriptionResponse, error) {
+	info, err := os.Stat(audioFile)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
 	}
 
-	return &Transcriber{
-		apiKey:     apiKey,
-		appDataDir: appDataDir,
+	// Files over the upload limit are split and the pieces stitched back together
+	var result *TranscriptionResponse
+	if info.Size() > maxUploadBytes {
+		result, err = t.transcribeChunked(audioFile, opts)
+	} else {
+		result, err = t.request(audioFile, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Model = Model
+	result.Translation = opts.Translate
+
+	// Post-process the text, keeping what the API returned for reference
+	if t.processor != nil {
+		language := opts.Language
+		if language == "" || opts.Translate {
+			language = result.Language
+		}
+		if processed := t.processor.Apply(result.Text, language); processed != result.Text {
+			result.RawText = result.Text
+			result.Text = processed
+		}
+	}
+
+	if t.redactor != nil {
+		t.redact(result)
+	}
+
+	// Remove the recording first so the index doesn't point at it
+	if t.deleteRedactedAudio && result.Redactions > 0 {
+		if err := os.Remove(audioFile); err != nil {
+			return nil, fmt.Errorf("failed to delete redacted recording: %w", err)
+		}
 	}
+
+	// Save the text along with segments, word timings, language and duration
+	if err := t.store.Save(audioFile, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
-func (t *Transcriber) Transcribe(audioFile string) (string, error) {
+// request uploads a single audio file to the transcriptions or translations API
+func (t *Transcriber) request(audioFile string, opts TranscribeOptions) (*TranscriptionResponse, error) {
 	file, err := os.Open(audioFile)
 	if err != nil {
-		return "", fmt.Errorf("failed to open audio file: %w", err)
+		return nil, fmt.Errorf("failed to open audio file: %w", err)
 	}
 	defer file.Close()
 
@@ -49,25 +176,53 @@ func (t *Transcriber) Transcribe(audioFile string) (string, error) {
 	// Add the file field
 	part, err := writer.CreateFormFile("file", filepath.Base(audioFile))
 	if err != nil {
-		return "", fmt.Errorf("failed to create form file: %w", err)
+		return nil, fmt.Errorf("failed to create form file: %w", err)
 	}
 	if _, err := io.Copy(part, file); err != nil {
-		return "", fmt.Errorf("failed to copy file data: %w", err)
+		return nil, fmt.Errorf("failed to copy file data: %w", err)
 	}
 
 	// Add the model field
-	if err := writer.WriteField("model", "whisper-1"); err != nil {
-		return "", fmt.Errorf("failed to write model field: %w", err)
+	if err := writer.WriteField("model", Model); err != nil {
+		return nil, fmt.Errorf("failed to write model field: %w", err)
+	}
+
+	// Ask for segments along with the detected language and duration
+	if err := writer.WriteField("response_format", "verbose_json"); err != nil {
+		return nil, fmt.Errorf("failed to write response format field: %w", err)
+	}
+
+	if t.prompt != "" {
+		if err := writer.WriteField("prompt", t.prompt); err != nil {
+			return nil, fmt.Errorf("failed to write prompt field: %w", err)
+		}
+	}
+
+	url := translationsURL
+	if !opts.Translate {
+		url = transcriptionsURL
+
+		// Word timings and the language hint are only supported when transcribing
+		for _, granularity := range []string{"segment", "word"} {
+			if err := writer.WriteField("timestamp_granularities[]", granularity); err != nil {
+				return nil, fmt.Errorf("failed to write timestamp granularity field: %w", err)
+			}
+		}
+		if opts.Language != "" {
+			if err := writer.WriteField("language", opts.Language); err != nil {
+				return nil, fmt.Errorf("failed to write language field: %w", err)
+			}
+		}
 	}
 
 	if err := writer.Close(); err != nil {
-		return "", fmt.Errorf("failed to close writer: %w", err)
+		return nil, fmt.Errorf("failed to close writer: %w", err)
 	}
 
 	// Create the request
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/audio/transcriptions", &buf)
+	req, err := http.NewRequest("POST", url, &buf)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
@@ -78,27 +233,40 @@ func (t *Transcriber) Transcribe(audioFile string) (string, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	// Parse the response
 	var result TranscriptionResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	// Save transcription to file
-	timestamp := filepath.Base(audioFile[:len(audioFile)-4]) // Remove .wav extension
-	transcriptionFile := filepath.Join(t.appDataDir, config.TranscriptionsDir, timestamp+".txt")
-	if err := os.WriteFile(transcriptionFile, []byte(result.Text), 0644); err != nil {
-		return "", fmt.Errorf("failed to save transcription: %w", err)
+	return &result, nil
+}
+
+// redact masks sensitive data in every copy of the text kept in the response.
+// Word timings are dropped when anything was found since a number read out
+// digit by digit spans several words and can't be masked word by word.
+func (t *Transcriber) redact(result *TranscriptionResponse) {
+	result.Text, result.Redactions = t.redactor.Redact(result.Text)
+	if result.RawText != "" {
+		result.RawText, _ = t.redactor.Redact(result.RawText)
 	}
 
-	return result.Text, nil
-} 
\ No newline at end of file
+	found := result.Redactions
+	for i := range result.Segments {
+		var n int
+		result.Segments[i].Text, n = t.redactor.Redact(result.Segments[i].Text)
+		found += n
+	}
+	if found > 0 {
+		result.Words = nil
+	}
+}
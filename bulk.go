@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"lazywhisper/audio"
+	"lazywhisper/config"
+	"lazywhisper/notes"
+	"lazywhisper/store"
+	"lazywhisper/subtitle"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// bulkJob runs an action over several transcriptions one at a time so progress
+// can be shown between items
+type bulkJob struct {
+	// verb describes the action in progress, e.g. "Exporting"
+	verb string
+	// done describes the finished action, e.g. "Exported"
+	done string
+	ids  []string
+	run  func(id string) error
+	// finish runs after the last item with the ids that succeeded
+	finish func(m model, succeeded []string) (model, tea.Cmd)
+
+	next      int
+	succeeded []string
+	failures  []string
+}
+
+type bulkStepMsg struct {
+	id  string
+	err error
+}
+
+// markedIDs returns the marked transcriptions in list order
+func (m model) markedIDs() []string {
+	var ids []string
+	for _, entry := range m.allTranscriptions {
+		if m.marked[entry.ID] {
+			ids = append(ids, entry.ID)
+		}
+	}
+	return ids
+}
+
+// toggleMark marks or unmarks the selected transcription and moves to the next one
+func (m model) toggleMark() model {
+	id := m.transcriptionFiles[m.selectedIndex].ID
+	marked := make(map[string]bool, len(m.marked)+1)
+	for k, v := range m.marked {
+		marked[k] = v
+	}
+	if marked[id] {
+		delete(marked, id)
+	} else {
+		marked[id] = true
+	}
+	m.marked = marked
+
+	if m.selectedIndex < len(m.transcriptionFiles)-1 {
+		m = m.selectTranscription(m.selectedIndex + 1)
+	}
+	return m
+}
+
+// markRange starts a range at the selected transcription, or marks everything
+// between the start of the range and the selection
+func (m model) markRange() model {
+	if m.rangeAnchor < 0 {
+		m.rangeAnchor = m.selectedIndex
+		return m
+	}
+
+	from, to := min(m.rangeAnchor, m.selectedIndex), max(m.rangeAnchor, m.selectedIndex)
+	marked := make(map[string]bool, len(m.marked)+to-from+1)
+	for k, v := range m.marked {
+		marked[k] = v
+	}
+	for i := from; i <= to && i < len(m.transcriptionFiles); i++ {
+		marked[m.transcriptionFiles[i].ID] = true
+	}
+	m.marked = marked
+	m.rangeAnchor = -1
+	return m
+}
+
+// clearMarks drops the marks and any range in progress
+func (m model) clearMarks() model {
+	m.marked = nil
+	m.rangeAnchor = -1
+	return m
+}
+
+// startBulk runs job over its ids, showing progress in the status line
+func (m model) startBulk(job *bulkJob) (model, tea.Cmd) {
+	if len(job.ids) == 0 {
+		return m, nil
+	}
+	m.bulk = job
+	m.statusMessage = fmt.Sprintf("%s 0/%d…", job.verb, len(job.ids))
+	return m, job.step()
+}
+
+func (j *bulkJob) step() tea.Cmd {
+	id, run := j.ids[j.next], j.run
+	return func() tea.Msg {
+		return bulkStepMsg{id: id, err: run(id)}
+	}
+}
+
+// handleBulkStep records the result of one item and starts the next, or
+// finishes with a summary of what failed
+func (m model) handleBulkStep(msg bulkStepMsg) (model, tea.Cmd) {
+	job := m.bulk
+	if job == nil {
+		return m, nil
+	}
+
+	if msg.err != nil {
+		job.failures = append(job.failures, fmt.Sprintf("%s: %v", msg.id, msg.err))
+	} else {
+		job.succeeded = append(job.succeeded, msg.id)
+	}
+	job.next++
+
+	if job.next < len(job.ids) {
+		m.statusMessage = fmt.Sprintf("%s %d/%d…", job.verb, job.next, len(job.ids))
+		return m, job.step()
+	}
+
+	m.bulk = nil
+	m = m.clearMarks()
+	m.statusMessage = bulkSummary(job)
+
+	cmds := []tea.Cmd{loadTranscriptions(m.store)}
+	if job.finish != nil {
+		var cmd tea.Cmd
+		m, cmd = job.finish(m, job.succeeded)
+		cmds = append(cmds, cmd)
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// bulkSummary reports how many items succeeded and why the others failed
+func bulkSummary(job *bulkJob) string {
+	summary := fmt.Sprintf("%s %d of %d", job.done, len(job.succeeded), len(job.ids))
+	if len(job.failures) == 0 {
+		return successStyle.Render(summary + " ✓")
+	}
+	return errorStyle.Render(summary+". Failed:") + "\n" + strings.Join(job.failures, "\n")
+}
+
+// bulkTrash moves the marked transcriptions to the trash, offering to undo it
+func (m model) bulkTrash(ids []string) (model, tea.Cmd) {
+	m.player.Stop()
+	return m.startBulk(&bulkJob{
+		verb: "Moving to trash",
+		done: "Moved to trash",
+		ids:  ids,
+		run:  m.store.Trash,
+		finish: func(m model, succeeded []string) (model, tea.Cmd) {
+			return m.offerUndo(succeeded)
+		},
+	})
+}
+
+// bulkExport writes SRT and WebVTT files for the marked transcriptions
+func (m model) bulkExport(ids []string) (model, tea.Cmd) {
+	s := m.store
+	return m.startBulk(&bulkJob{
+		verb: "Exporting subtitles",
+		done: "Exported subtitles for",
+		ids:  ids,
+		run: func(id string) error {
+			for _, format := range []subtitle.Format{subtitle.SRT, subtitle.VTT} {
+				outPath := filepath.Join(s.AppDataDir(), config.SubtitlesDir, id+"."+string(format))
+				if err := writeSubtitles(s, id, format, outPath); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// bulkTag adds tags to the marked transcriptions, keeping the tags they already have
+func (m model) bulkTag(ids []string, tags []string) (model, tea.Cmd) {
+	s := m.store
+	return m.startBulk(&bulkJob{
+		verb: "Tagging",
+		done: "Tagged",
+		ids:  ids,
+		run: func(id string) error {
+			return s.Update(id, func(e *store.Entry) {
+				e.Tags = store.ParseTags(strings.Join(append(e.Tags, tags...), ","))
+			})
+		},
+	})
+}
+
+// bulkCopy copies the marked transcriptions to the clipboard, separated by blank lines
+func (m model) bulkCopy(ids []string) (model, tea.Cmd) {
+	s := m.store
+	texts := map[string]string{}
+	return m.startBulk(&bulkJob{
+		verb: "Reading",
+		done: "Copied",
+		ids:  ids,
+		run: func(id string) error {
+			text, err := s.Text(id)
+			texts[id] = strings.TrimSpace(text)
+			return err
+		},
+		finish: func(m model, succeeded []string) (model, tea.Cmd) {
+			parts := make([]string, len(succeeded))
+			for i, id := range succeeded {
+				parts[i] = texts[id]
+			}
+			return m, copyToClipboard(strings.Join(parts, "\n\n"))
+		},
+	})
+}
+
+// exportNotes writes Markdown notes for ids into the notes directory
+func (m model) exportNotes(ids []string) (model, tea.Cmd) {
+	if m.notes == nil {
+		m.statusMessage = "Set notes.dir in config.json to export notes"
+		return m, tick
+	}
+	exporter := m.notes
+	skipped := map[string]notes.Status{}
+	return m.startBulk(&bulkJob{
+		verb: "Exporting notes",
+		done: "Exported notes for",
+		ids:  ids,
+		run: func(id string) error {
+			result, err := exporter.Export(id)
+			if result.Status == notes.Edited || result.Status == notes.Removed {
+				skipped[id] = result.Status
+			}
+			return err
+		},
+		finish: func(m model, succeeded []string) (model, tea.Cmd) {
+			for _, id := range succeeded {
+				if status, ok := skipped[id]; ok {
+					m.statusMessage += fmt.Sprintf("\n%s: %s", id, status)
+				}
+			}
+			return m, nil
+		},
+	})
+}
+
+// retranscribe sends the recordings of ids through the transcriber again with
+// the current language and translate settings
+func (m model) retranscribe(ids []string) (model, tea.Cmd) {
+	s, transcriber, opts := m.store, m.transcriber, m.transcribeOptions()
+	return m.startBulk(&bulkJob{
+		verb: "Transcribing",
+		done: "Transcribed",
+		ids:  ids,
+		run: func(id string) error {
+			return retranscribeOne(s, transcriber, opts, id)
+		},
+	})
+}
+
+func retranscribeOne(s *store.Store, transcriber *audio.Transcriber, opts audio.TranscribeOptions, id string) error {
+	entry, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	audioFile := s.AudioFile(entry)
+	if audioFile == "" {
+		return fmt.Errorf("the recording is gone")
+	}
+	// Encrypted recordings are uploaded from a temporary plaintext copy
+	plainFile, cleanup, err := s.Cipher().TempPlaintext(audioFile)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	_, err = transcriber.Transcribe(plainFile, opts)
+	return err
+}
+
+// selectionIDs is what an action applies to: the marked transcriptions, or the selected one
+func (m model) selectionIDs() []string {
+	if ids := m.markedIDs(); len(ids) > 0 {
+		return ids
+	}
+	if len(m.transcriptionFiles) == 0 {
+		return nil
+	}
+	return []string{m.transcriptionFiles[m.selectedIndex].ID}
+}
+
+// markLine shows how many transcriptions are marked and whether a range is being marked
+func (m model) markLine() string {
+	var parts []string
+	if n := len(m.markedIDs()); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d marked", n))
+	}
+	if m.rangeAnchor >= 0 {
+		parts = append(parts, "press v again to mark the range")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return helpStyle.Render(strings.Join(parts, ", ")) + "\n\n"
+}
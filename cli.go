@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"lazywhisper/config"
+	"lazywhisper/hooks"
+	"lazywhisper/notes"
+	"lazywhisper/store"
+	"lazywhisper/subtitle"
+	"lazywhisper/watch"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const usage = `Usage:
+  lazywhisper                 Start the interactive recorder
+  lazywhisper watch [--dir]   Import and transcribe audio dropped into the watch folder
+  lazywhisper export --format srt|vtt [--out file] <id>
+                              Write subtitles for a transcription
+  lazywhisper export --archive <file.tar.gz>
+                              Bundle every transcription, recording and the index into an archive
+  lazywhisper import --archive <file.tar.gz>
+                              Merge an archive into this machine's transcriptions
+  lazywhisper export-notes [id...]
+                              Write transcriptions as Markdown notes into notes.dir
+  lazywhisper search [--json] <query>
+                              Search titles and text of all transcriptions
+  lazywhisper sync [--keep-local|--keep-remote]
+                              Pull and push transcriptions with the sync remote
+  lazywhisper serve [--addr host:port]
+                              Serve the HTTP API for recording, transcribing and searching
+  lazywhisper webhooks [--last n]
+                              Show the most recent webhook delivery attempts
+  lazywhisper gc [--dry-run]  Delete recordings the retention settings no longer keep
+  lazywhisper encrypt-existing
+                              Encrypt files saved before encryption was enabled
+  lazywhisper help            Show this message
+`
+
+// runCommand handles the non-interactive subcommands and returns the process exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "watch":
+		return runWatch(args[1:])
+	case "export":
+		return runExport(args[1:])
+	case "import":
+		return runImport(args[1:])
+	case "export-notes":
+		return runExportNotes(args[1:])
+	case "search":
+		return runSearch(args[1:])
+	case "sync":
+		return runSync(args[1:])
+	case "serve":
+		return runServe(args[1:])
+	case "webhooks":
+		return runWebhooks(args[1:])
+	case "gc":
+		return runGC(args[1:])
+	case "encrypt-existing":
+		return runEncryptExisting(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// runWatch runs the watch folder ingestion in the foreground until interrupted
+func runWatch(args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	dir := fs.String("dir", "", "folder to watch (defaults to watch.dir in config.json)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := checkDependencies(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *dir != "" {
+		settings.Watch.Dir = config.ExpandHome(*dir)
+	}
+
+	transcriptions, err := openStore(settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := purgeExpiredTrash(transcriptions, settings.Trash); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if _, _, err := applyRetention(transcriptions, settings.Retention); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	hookRunner := hooks.New(settings.Hooks)
+	transcriber, saver, err := newTranscriber(os.Getenv("OPENAI_API_KEY"), settings, transcriptions, hookRunner)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	w, err := watch.New(settings, transcriber)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	if settings.Sync.Enabled && settings.Sync.IntervalMinutes > 0 {
+		repo, err := openSync(settings.Sync, transcriptions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		interval := time.Duration(settings.Sync.IntervalMinutes) * time.Minute
+		go syncInBackground(repo, transcriptions, interval, done, func(line string) {
+			fmt.Println(line)
+		})
+	}
+
+	fmt.Printf("Watching %s (Ctrl+C to stop)\n", w.Dir())
+	for {
+		select {
+		case <-done:
+			// A second Ctrl+C quits without waiting
+			stop()
+			if saver.Pending() {
+				fmt.Println("Waiting for webhook deliveries (Ctrl+C to quit now)...")
+			}
+			// Let webhooks and hooks for the last files finish
+			saver.Wait()
+			hookRunner.Wait()
+			return 0
+		case e := <-w.Events():
+			fmt.Println(formatWatchEvent(e, w.Status()))
+			if e.Kind == watch.Failed {
+				hookRunner.Fire(hooks.Error, hooks.Env{AudioFile: e.Source, Error: e.Err.Error()})
+			}
+		case run := <-hookRunner.Runs():
+			fmt.Println(formatHookRun(run))
+		case err := <-saver.Warnings():
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// formatHookRun renders a finished hook as a log line followed by its indented output
+func formatHookRun(run hooks.Run) string {
+	line := fmt.Sprintf("hook       %s ok (%.1fs)", run.Event, run.Duration.Seconds())
+	if run.Err != nil {
+		line = fmt.Sprintf("hook       %s failed: %v (%.1fs)", run.Event, run.Err, run.Duration.Seconds())
+	}
+	if output := strings.TrimRight(run.Output, "\n"); output != "" {
+		line += "\n    " + strings.ReplaceAll(output, "\n", "\n    ")
+	}
+	return line
+}
+
+// formatWatchEvent renders a watcher event as a single log line
+func formatWatchEvent(e watch.Event, status watch.Status) string {
+	var line string
+	switch e.Kind {
+	case watch.Queued:
+		line = fmt.Sprintf("queued     %s", e.Source)
+	case watch.Started:
+		line = fmt.Sprintf("processing %s", e.Source)
+	case watch.Completed:
+		line = fmt.Sprintf("done       %s -> %s", e.Source, e.ID)
+	case watch.Failed:
+		line = fmt.Sprintf("failed     %s: %v", e.Source, e.Err)
+	}
+	return fmt.Sprintf("%s (%d queued, %d processing)", line, status.Queued, status.Processing)
+}
+
+// runExport writes subtitles for a single transcription, or with --archive everything
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := fs.String("format", "srt", "subtitle format: srt or vtt")
+	out := fs.String("out", "", "output file (defaults to <id>.<format> in the current directory)")
+	archive := fs.String("archive", "", "bundle every transcription into this .tar.gz instead")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *archive != "" {
+		if fs.NArg() != 0 {
+			fmt.Fprintf(os.Stderr, "--archive exports every transcription and takes no id\n\n%s", usage)
+			return 2
+		}
+		return runExportArchive(*archive)
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Expected a transcription id\n\n%s", usage)
+		return 2
+	}
+
+	format, err := subtitle.ParseFormat(*formatName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+
+	id := transcriptionID(fs.Arg(0))
+	outPath := *out
+	if outPath == "" {
+		outPath = id + "." + string(format)
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	transcriptions, err := openStore(settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if _, err := transcriptions.Get(id); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if err := writeSubtitles(transcriptions, id, format, outPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Println(outPath)
+	return 0
+}
+
+// runExportNotes writes Markdown notes for the given transcriptions, or for all of them
+func runExportNotes(args []string) int {
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if settings.Notes.Dir == "" {
+		fmt.Fprintf(os.Stderr, "Error: set notes.dir in %s first\n", config.SettingsFile)
+		return 1
+	}
+	transcriptions, err := openStore(settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	exporter, err := notes.New(settings.Notes, transcriptions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var results []notes.Result
+	if len(args) == 0 {
+		results, err = exporter.ExportAll()
+	} else {
+		for _, arg := range args {
+			var result notes.Result
+			if result, err = exporter.Export(transcriptionID(arg)); err != nil {
+				break
+			}
+			results = append(results, result)
+		}
+	}
+
+	for _, result := range results {
+		fmt.Printf("%-9s %s\n", result.Status, result.Path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// transcriptionID accepts either a bare id or a transcription filename
+func transcriptionID(arg string) string {
+	return store.IDFromPath(arg)
+}
+
+// writeSubtitles builds cues from a transcription's sidecar and writes them to outPath
+func writeSubtitles(s *store.Store, id string, format subtitle.Format, outPath string) error {
+	details, err := s.Details(id)
+	if err != nil {
+		return err
+	}
+	if len(details.Segments) == 0 {
+		return fmt.Errorf("transcription %s has no segment timestamps", id)
+	}
+
+	file, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", outPath, err)
+	}
+	defer file.Close()
+
+	if err := subtitle.Write(file, format, subtitle.Build(details, subtitle.DefaultOptions())); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outPath, err)
+	}
+	return nil
+}
+
+// runSearch prints the transcriptions matching a query, best match first
+func runSearch(args []string) int {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Expected a search query\n\n%s", usage)
+		return 2
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	transcriptions, err := openStore(settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	results, err := transcriptions.Search(strings.Join(fs.Args(), " "))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if *asJSON {
+		if results == nil {
+			results = []store.Result{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	for _, result := range results {
+		line := result.Entry.ID
+		if result.Entry.Title != "" {
+			line += "  " + result.Entry.Title
+		}
+		fmt.Println(line)
+		if result.Snippet != "" {
+			fmt.Printf("    %s\n", result.Snippet)
+		}
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "No matches")
+		return 1
+	}
+	return 0
+}
+
+// runGC applies the retention settings, or with --dry-run lists what they would delete
+func runGC(args []string) int {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "list the recordings that would be deleted without deleting them")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	transcriptions, err := openStore(settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	policy := retentionPolicy(settings.Retention)
+	if policy.AudioMaxAge == 0 && policy.MaxBytes == 0 {
+		fmt.Println("No retention rules are configured; set retention in config.json")
+		return 0
+	}
+	evictions, err := transcriptions.PlanRetention(policy, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var freed int64
+	for _, eviction := range evictions {
+		if !*dryRun {
+			if err := transcriptions.RemoveAudio(eviction.Entry.ID); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+		}
+		fmt.Printf("%s  %10s  %s\n", eviction.Entry.ID, formatBytes(eviction.Bytes), eviction.Reason)
+		freed += eviction.Bytes
+	}
+
+	verb := "Deleted"
+	if *dryRun {
+		verb = "Would delete"
+	}
+	fmt.Printf("%s %d recordings, %s\n", verb, len(evictions), formatBytes(freed))
+	return 0
+}
+
+// runEncryptExisting encrypts the transcriptions and recordings saved before encryption was enabled
+func runEncryptExisting(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments: %s\n\n%s", strings.Join(args, " "), usage)
+		return 2
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if !settings.Encryption.Enabled {
+		fmt.Fprintf(os.Stderr, "Error: set encryption.enabled in %s first\n", config.SettingsFile)
+		return 1
+	}
+	transcriptions, err := openStore(settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	encrypted, err := transcriptions.EncryptExisting()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v (%d files encrypted so far; run again to continue)\n", err, encrypted)
+		return 1
+	}
+	fmt.Printf("Encrypted %d files\n", encrypted)
+	return 0
+}
@@ -7,9 +7,13 @@ import (
 )
 
 const (
-	AppName = "open_whisper"
-	RecordingsDir = "recordings"
+	AppName           = "open_whisper"
+	RecordingsDir     = "recordings"
 	TranscriptionsDir = "transcriptions"
+	SubtitlesDir      = "subtitles"
+	DerivedDir        = "derived"
+	RevisionsDir      = "revisions"
+	TrashDir          = "trash"
 )
 
 // GetAppDataDir returns the application data directory path and ensures all required subdirectories exist
@@ -23,16 +27,38 @@ func GetAppDataDir() (string, error) {
 	// Create app data directory path
 	appDataDir := filepath.Join(homeDir, "."+AppName)
 
-	// Create directories if they don't exist
+	if err := CreateDirs(appDataDir); err != nil {
+		return "", err
+	}
+	return appDataDir, nil
+}
+
+// CreateDirs creates appDataDir and its subdirectories if they don't exist
+func CreateDirs(appDataDir string) error {
 	for _, dir := range []string{
 		appDataDir,
 		filepath.Join(appDataDir, RecordingsDir),
 		filepath.Join(appDataDir, TranscriptionsDir),
+		filepath.Join(appDataDir, SubtitlesDir),
+		filepath.Join(appDataDir, DerivedDir),
+		filepath.Join(appDataDir, RevisionsDir),
+		filepath.Join(appDataDir, TrashDir),
 	} {
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
+	return nil
+}
 
-	return appDataDir, nil
-} 
\ No newline at end of file
+// TempDir returns a private directory under the system temp directory for
+// plaintext recordings, which must stay out of the app data directory while
+// encryption is on. The name includes the app name so orphaned ffmpeg captures
+// into it are still found and stopped.
+func TempDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf(".%s-%d", AppName, os.Getuid()))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,399 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const SettingsFile = "config.json"
+
+// Settings holds the user configurable options read from config.json in the app data directory
+type Settings struct {
+	Transcription TranscriptionSettings `json:"transcription"`
+	Processing    ProcessingSettings    `json:"processing"`
+	Rewrite       RewriteSettings       `json:"rewrite"`
+	Watch         WatchSettings         `json:"watch"`
+	Playback      PlaybackSettings      `json:"playback"`
+	Trash         TrashSettings         `json:"trash"`
+	Retention     RetentionSettings     `json:"retention"`
+	Encryption    EncryptionSettings    `json:"encryption"`
+	Notes         NotesSettings         `json:"notes"`
+	Journal       JournalSettings       `json:"journal"`
+	Sync          SyncSettings          `json:"sync"`
+	Hooks         HooksSettings         `json:"hooks"`
+	Webhooks      WebhooksSettings      `json:"webhooks"`
+	Serve         ServeSettings         `json:"serve"`
+}
+
+// TranscriptionSettings configures requests to the transcription API
+type TranscriptionSettings struct {
+	// Language is the default ISO-639-1 code sent to the API. Empty means auto-detect.
+	Language string `json:"language"`
+	// Languages are the codes cycled through with the language key in the TUI
+	Languages []string `json:"languages"`
+	// Vocabulary lists product names, people and jargon sent as the prompt to bias spelling
+	Vocabulary []string `json:"vocabulary"`
+}
+
+// ProcessingSettings configures the local clean up applied to text before it is saved and copied
+type ProcessingSettings struct {
+	// Replacements are applied in order to every transcription
+	Replacements []Replacement `json:"replacements"`
+	// SpokenCommands turns phrases like "new line" into formatting
+	SpokenCommands SpokenCommandSettings `json:"spoken_commands"`
+	// Cleanup removes filler words, stutters and false starts
+	Cleanup CleanupSettings `json:"cleanup"`
+	// Redaction masks personal data and secrets before anything is saved or copied
+	Redaction RedactionSettings `json:"redaction"`
+}
+
+// RedactionSettings configures the local PII redaction stage
+type RedactionSettings struct {
+	Enabled bool `json:"enabled"`
+	// Detectors lists the built-in detectors to run: email, phone, card and
+	// api_key. All of them run when empty.
+	Detectors []string `json:"detectors"`
+	// Custom are additional named regular expressions to mask
+	Custom []RedactionPattern `json:"custom"`
+	// Mask replaces each match. {type} is replaced with the detector name.
+	Mask string `json:"mask"`
+	// DeleteAudio removes the recording when anything was redacted from it
+	DeleteAudio bool `json:"delete_audio"`
+}
+
+// RedactionPattern is a user defined redaction detector
+type RedactionPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// CleanupSettings configures the filler word and disfluency clean up
+type CleanupSettings struct {
+	Enabled bool `json:"enabled"`
+	// Fillers maps ISO-639-1 codes (or "*" for every language) to filler words
+	// and phrases, replacing the built-in list for that language
+	Fillers map[string][]string `json:"fillers"`
+}
+
+// SpokenCommandSettings configures dictation commands
+type SpokenCommandSettings struct {
+	Enabled bool `json:"enabled"`
+	// Commands are added to the built-in commands, replacing any with the same phrase
+	Commands []SpokenCommand `json:"commands"`
+}
+
+// SpokenCommand maps a spoken phrase to an edit
+type SpokenCommand struct {
+	Phrase string `json:"phrase"`
+	// Action is "insert" (the default) or "scratch", which removes the previous sentence
+	Action string `json:"action"`
+	// Insert is the text that replaces the phrase for insert commands
+	Insert string `json:"insert"`
+	// AttachPrevious joins the insert to the previous word without a space
+	AttachPrevious bool `json:"attach_previous"`
+	// AttachNext joins the insert to the next word without a space
+	AttachNext bool `json:"attach_next"`
+}
+
+// Replacement is a single find/replace dictionary entry
+type Replacement struct {
+	Find    string `json:"find"`
+	Replace string `json:"replace"`
+	// Regex treats Find as a regular expression instead of literal text
+	Regex bool `json:"regex"`
+	// CaseSensitive only matches Find with the exact same casing
+	CaseSensitive bool `json:"case_sensitive"`
+	// WholeWord only matches Find when it is not part of a larger word
+	WholeWord bool `json:"whole_word"`
+}
+
+// RewriteSettings configures the chat completions endpoint used for rewrite profiles
+type RewriteSettings struct {
+	// BaseURL is an OpenAI-compatible API root, e.g. http://localhost:11434/v1
+	BaseURL string `json:"base_url"`
+	// Model is used for profiles that don't name their own
+	Model string `json:"model"`
+	// APIKeyEnv names the environment variable holding the API key
+	APIKeyEnv string           `json:"api_key_env"`
+	Profiles  []RewriteProfile `json:"profiles"`
+}
+
+// RewriteProfile is a named system prompt applied to a transcription
+type RewriteProfile struct {
+	Name         string `json:"name"`
+	SystemPrompt string `json:"system_prompt"`
+	Model        string `json:"model"`
+	// Auto runs the profile after every new transcription
+	Auto bool `json:"auto"`
+}
+
+// WatchSettings configures the watch-folder ingestion mode
+type WatchSettings struct {
+	// Dir is the folder to watch for new audio files. Watching is disabled when empty.
+	Dir string `json:"dir"`
+	// Workers bounds how many files are imported and transcribed at the same time
+	Workers int `json:"workers"`
+	// IntervalSeconds is how often the folder is scanned for new files
+	IntervalSeconds int `json:"interval_seconds"`
+}
+
+// PlaybackSettings configures how recordings are played from the transcription list
+type PlaybackSettings struct {
+	// Command plays a file without a window. {file} is replaced with the
+	// recording and {start} with the position in seconds to start from.
+	Command []string `json:"command"`
+}
+
+// TrashSettings configures how long deleted transcriptions can be restored
+type TrashSettings struct {
+	// PurgeAfterDays permanently removes trashed transcriptions after this many
+	// days. Zero keeps them until purged by hand.
+	PurgeAfterDays int `json:"purge_after_days"`
+}
+
+// RetentionSettings configures when recordings are deleted to save space.
+// Transcription text is always kept.
+type RetentionSettings struct {
+	// DeleteAudioAfterDays deletes recordings older than this many days. Zero keeps them.
+	DeleteAudioAfterDays int `json:"delete_audio_after_days"`
+	// MaxDiskGB caps the size of the app data directory, not counting the
+	// trash, deleting the oldest recordings first. Zero means no cap.
+	MaxDiskGB float64 `json:"max_disk_gb"`
+}
+
+// EncryptionSettings configures encryption of recordings and transcriptions at rest
+type EncryptionSettings struct {
+	Enabled bool `json:"enabled"`
+	// KeyFile derives the key from the contents of a file instead of a passphrase
+	KeyFile string `json:"key_file"`
+	// PassphraseEnv names the environment variable holding the passphrase. The
+	// passphrase is asked for when it isn't set and there is no key file.
+	PassphraseEnv string `json:"passphrase_env"`
+}
+
+// NotesSettings configures exporting transcriptions as Markdown notes, e.g.
+// into an Obsidian or Logseq vault
+type NotesSettings struct {
+	// Dir is the vault directory notes are written to
+	Dir string `json:"dir"`
+	// Filename is the note's path inside Dir without the .md extension.
+	// {date}, {time}, {id} and {title} are filled in.
+	Filename string `json:"filename"`
+	// AutoExport writes a note for every new transcription
+	AutoExport bool `json:"auto_export"`
+}
+
+// JournalSettings configures appending every new transcription to a daily journal file
+type JournalSettings struct {
+	Enabled bool `json:"enabled"`
+	// Path is the journal file. {date} is replaced with the day of the recording;
+	// {time}, {id}, {language} and {duration} work too. Each can also be written
+	// as {{date}} and so on.
+	Path string `json:"path"`
+	// Format is appended for each transcription. {date}, {time}, {id}, {title},
+	// {language}, {duration} and {text} are filled in, with single or double braces.
+	Format string `json:"format"`
+}
+
+// SyncSettings configures keeping the transcriptions directory in a git
+// repository that is pushed to and pulled from a remote
+type SyncSettings struct {
+	Enabled bool `json:"enabled"`
+	// Remote is the URL or path of the repository to sync with
+	Remote string `json:"remote"`
+	Branch string `json:"branch"`
+	// IntervalMinutes syncs in the background this often while the app or
+	// watch mode runs. Zero only syncs on demand.
+	IntervalMinutes int `json:"interval_minutes"`
+}
+
+// HooksSettings configures shell commands run when recording and transcription
+// events happen. Each command runs with sh -c and details in LAZYWHISPER_*
+// environment variables. Empty commands are skipped.
+type HooksSettings struct {
+	OnRecordingStart        string `json:"on_recording_start"`
+	OnRecordingStop         string `json:"on_recording_stop"`
+	OnTranscriptionComplete string `json:"on_transcription_complete"`
+	OnError                 string `json:"on_error"`
+	// TimeoutSeconds kills a hook that runs longer than this
+	TimeoutSeconds int `json:"timeout_seconds"`
+}
+
+// WebhooksSettings configures posting every new transcription to HTTP endpoints
+type WebhooksSettings struct {
+	Endpoints []WebhookEndpoint `json:"endpoints"`
+	// MaxAttempts is how many times a delivery is tried before giving up
+	MaxAttempts int `json:"max_attempts"`
+}
+
+// WebhookEndpoint is a URL new transcriptions are posted to
+type WebhookEndpoint struct {
+	URL string `json:"url"`
+	// SecretEnv names the environment variable holding the key payloads are
+	// signed with. Payloads aren't signed when empty.
+	SecretEnv string `json:"secret_env"`
+	// IncludeAudio adds the recording to the payload, base64 encoded
+	IncludeAudio bool `json:"include_audio"`
+}
+
+// ServeSettings configures the HTTP API started with lazywhisper serve
+type ServeSettings struct {
+	// Addr is the address the API listens on
+	Addr string `json:"addr"`
+	// TokenEnv names the environment variable holding the bearer token. When
+	// it isn't set, a token is generated and kept in the app data directory.
+	TokenEnv string `json:"token_env"`
+}
+
+// DefaultSettings returns the settings used when no config file exists
+func DefaultSettings() *Settings {
+	return &Settings{
+		Transcription: TranscriptionSettings{
+			Languages: []string{"en", "es", "fr", "de"},
+		},
+		Rewrite: RewriteSettings{
+			BaseURL:   "https://api.openai.com/v1",
+			Model:     "gpt-4o-mini",
+			APIKeyEnv: "OPENAI_API_KEY",
+			Profiles: []RewriteProfile{
+				{
+					Name:         "Clean up grammar",
+					SystemPrompt: "Fix the grammar, punctuation and spelling of the user's dictated text. Keep the wording and meaning. Reply with only the corrected text.",
+				},
+				{
+					Name:         "Commit message",
+					SystemPrompt: "Turn the user's dictated notes into a git commit message: a short imperative subject line, a blank line, then a wrapped body if needed. Reply with only the commit message.",
+				},
+				{
+					Name:         "Bullet list",
+					SystemPrompt: "Turn the user's dictated text into a concise Markdown bullet list. Reply with only the list.",
+				},
+				{
+					Name:         "Summarize",
+					SystemPrompt: "Summarize the user's dictated text in a few sentences. Reply with only the summary.",
+				},
+			},
+		},
+		Watch: WatchSettings{
+			Workers:         2,
+			IntervalSeconds: 5,
+		},
+		Playback: PlaybackSettings{
+			Command: []string{"ffplay", "-nodisp", "-autoexit", "-loglevel", "quiet", "-ss", "{start}", "{file}"},
+		},
+		Trash: TrashSettings{
+			PurgeAfterDays: 30,
+		},
+		Encryption: EncryptionSettings{
+			PassphraseEnv: "LAZYWHISPER_PASSPHRASE",
+		},
+		Notes: NotesSettings{
+			Filename: "{date} {title}",
+		},
+		Journal: JournalSettings{
+			Path:   "~/notes/{date}.md",
+			Format: "## {time}\n\n{text}\n",
+		},
+		Sync: SyncSettings{
+			Branch: "main",
+		},
+		Hooks: HooksSettings{
+			TimeoutSeconds: 30,
+		},
+		Webhooks: WebhooksSettings{
+			MaxAttempts: 5,
+		},
+		Serve: ServeSettings{
+			Addr:     "127.0.0.1:7433",
+			TokenEnv: "LAZYWHISPER_API_TOKEN",
+		},
+	}
+}
+
+// LoadSettings reads config.json from the app data directory, falling back to defaults for anything not set
+func LoadSettings() (*Settings, error) {
+	appDataDir, err := GetAppDataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := DefaultSettings()
+	data, err := os.ReadFile(filepath.Join(appDataDir, SettingsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+
+	if err := json.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", SettingsFile, err)
+	}
+
+	// Guard against zero or negative values left in the file
+	if settings.Watch.Workers < 1 {
+		settings.Watch.Workers = 1
+	}
+	if settings.Watch.IntervalSeconds < 1 {
+		settings.Watch.IntervalSeconds = 1
+	}
+	settings.Watch.Dir = ExpandHome(settings.Watch.Dir)
+	settings.Encryption.KeyFile = ExpandHome(settings.Encryption.KeyFile)
+	settings.Notes.Dir = ExpandHome(settings.Notes.Dir)
+	if settings.Notes.Filename == "" {
+		settings.Notes.Filename = DefaultSettings().Notes.Filename
+	}
+	if settings.Journal.Path == "" {
+		settings.Journal.Path = DefaultSettings().Journal.Path
+	}
+	settings.Journal.Path = ExpandHome(settings.Journal.Path)
+	if settings.Journal.Format == "" {
+		settings.Journal.Format = DefaultSettings().Journal.Format
+	}
+	settings.Sync.Remote = ExpandHome(settings.Sync.Remote)
+	if settings.Sync.Branch == "" {
+		settings.Sync.Branch = DefaultSettings().Sync.Branch
+	}
+	if settings.Sync.IntervalMinutes < 0 {
+		settings.Sync.IntervalMinutes = 0
+	}
+	if settings.Hooks.TimeoutSeconds < 1 {
+		settings.Hooks.TimeoutSeconds = DefaultSettings().Hooks.TimeoutSeconds
+	}
+	if settings.Webhooks.MaxAttempts < 1 {
+		settings.Webhooks.MaxAttempts = 1
+	}
+	if settings.Serve.Addr == "" {
+		settings.Serve.Addr = DefaultSettings().Serve.Addr
+	}
+	if settings.Trash.PurgeAfterDays < 0 {
+		settings.Trash.PurgeAfterDays = 0
+	}
+	if settings.Retention.DeleteAudioAfterDays < 0 {
+		settings.Retention.DeleteAudioAfterDays = 0
+	}
+	if settings.Retention.MaxDiskGB < 0 {
+		settings.Retention.MaxDiskGB = 0
+	}
+	if len(settings.Playback.Command) == 0 {
+		settings.Playback.Command = DefaultSettings().Playback.Command
+	}
+
+	return settings, nil
+}
+
+// ExpandHome replaces a leading ~ in path with the user's home directory
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,232 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeyFile holds the salt the key is derived with and a value sealed with the
+// key, so a wrong passphrase is caught before anything is decrypted
+const KeyFile = "encryption.json"
+
+// magic starts every encrypted file so plaintext files can be told apart and
+// read as they are during a migration
+var magic = []byte("LWENC\x01")
+
+// check is sealed into the key file to verify the key
+var check = []byte("lazywhisper")
+
+var (
+	// ErrLocked is returned when reading an encrypted file without a key
+	ErrLocked = errors.New("file is encrypted and no passphrase or key file was given")
+	// ErrWrongKey is returned when the passphrase or key file doesn't match the one used before
+	ErrWrongKey = errors.New("wrong passphrase or key file")
+)
+
+// Cipher encrypts files with AES-256-GCM. A nil Cipher reads and writes
+// plaintext, so callers don't need to check whether encryption is enabled.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+type keyFile struct {
+	Salt  []byte `json:"salt"`
+	Check []byte `json:"check"`
+}
+
+// Unlock derives the key from secret, a passphrase or the contents of a key
+// file, with the salt kept in dir. The first unlock creates the salt.
+func Unlock(dir string, secret []byte) (*Cipher, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("the passphrase or key file is empty")
+	}
+
+	path := filepath.Join(dir, KeyFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return create(path, secret)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", KeyFile, err)
+	}
+
+	var stored keyFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", KeyFile, err)
+	}
+	c, err := derive(secret, stored.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if plain, err := c.Open(stored.Check); err != nil || !bytes.Equal(plain, check) {
+		return nil, ErrWrongKey
+	}
+	return c, nil
+}
+
+func create(path string, secret []byte) (*Cipher, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	c, err := derive(secret, salt)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := c.Seal(check)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(keyFile{Salt: salt, Check: sealed}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", KeyFile, err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save %s: %w", KeyFile, err)
+	}
+	return c, nil
+}
+
+func derive(secret, salt []byte) (*Cipher, error) {
+	key, err := scrypt.Key(secret, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// Encrypted reports whether data was written by Seal
+func Encrypted(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Seal encrypts plaintext. A nil Cipher returns it unchanged.
+func (c *Cipher) Seal(plaintext []byte) ([]byte, error) {
+	if c == nil {
+		return plaintext, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := append(append([]byte{}, magic...), nonce...)
+	return c.aead.Seal(out, nonce, plaintext, magic), nil
+}
+
+// Open decrypts data written by Seal. Plaintext is returned unchanged.
+func (c *Cipher) Open(data []byte) ([]byte, error) {
+	if !Encrypted(data) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, ErrLocked
+	}
+	body := data[len(magic):]
+	if len(body) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	nonce, sealed := body[:c.aead.NonceSize()], body[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, magic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// ReadFile reads and decrypts path
+func (c *Cipher) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := c.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return plaintext, nil
+}
+
+// WriteFile encrypts data and writes it to path
+func (c *Cipher) WriteFile(path string, data []byte, perm os.FileMode) error {
+	sealed, err := c.Seal(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, sealed, perm)
+}
+
+// EncryptFile encrypts a plaintext file in place. Files that are already
+// encrypted are left alone. It reports whether the file was changed.
+func (c *Cipher) EncryptFile(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if c == nil || Encrypted(data) {
+		return false, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	tmp := path + ".tmp"
+	if err := c.WriteFile(tmp, data, info.Mode().Perm()); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+	return true, nil
+}
+
+// TempPlaintext returns a path other programs can read path's plaintext from.
+// Encrypted files are decrypted into a private directory under the system
+// temp directory, never next to the original, keeping the file name; cleanup
+// removes it. Plaintext files are returned as they are.
+func (c *Cipher) TempPlaintext(path string) (string, func(), error) {
+	noop := func() {}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", noop, err
+	}
+	if !Encrypted(data) {
+		return path, noop, nil
+	}
+	plaintext, err := c.Open(data)
+	if err != nil {
+		return "", noop, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+
+	dir, err := os.MkdirTemp("", "lazywhisper-")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	tmp := filepath.Join(dir, filepath.Base(path))
+	if err := os.WriteFile(tmp, plaintext, 0600); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	return tmp, cleanup, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"lazywhisper/store"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type textSavedMsg struct {
+	id     string
+	undone bool
+	err    error
+}
+
+// startEditing opens the selected transcription in the built-in editor. The
+// entry is kept so a list reload while editing can't change what is saved.
+func (m model) startEditing() (model, tea.Cmd) {
+	editor := textarea.New()
+	// Transcriptions can be long, so lift the default size limits
+	editor.CharLimit = 0
+	editor.MaxHeight = 0
+	editor.ShowLineNumbers = false
+	editor.SetWidth(max(m.width-4, 20))
+	editor.SetHeight(max(m.viewport.Height-4, 5))
+	editor.SetValue(m.selectedContent)
+
+	m.editing = true
+	m.editor = editor
+	m.editingEntry = m.transcriptionFiles[m.selectedIndex]
+	return m, m.editor.Focus()
+}
+
+// handleEditUpdate passes keys to the editor. Ctrl+S saves and Esc discards the changes.
+func (m model) handleEditUpdate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.editing = false
+		return m, nil
+	case "ctrl+s":
+		m.editing = false
+		return m, saveText(m.store, m.editingEntry.ID, m.editor.Value())
+	}
+
+	var cmd tea.Cmd
+	m.editor, cmd = m.editor.Update(msg)
+	return m, cmd
+}
+
+// editorView shows the built-in editor in place of the list
+func (m model) editorView() string {
+	heading := fmt.Sprintf("Editing %s:", entryLabel(m.editingEntry))
+	return paddedStyle.Render(heading + "\n\n" + m.editor.View())
+}
+
+// saveText replaces a transcription's text, keeping the previous version as a revision
+func saveText(s *store.Store, id, text string) tea.Cmd {
+	return func() tea.Msg {
+		return textSavedMsg{id: id, err: s.SaveText(id, text)}
+	}
+}
+
+// undoEdit restores the previous revision of a transcription
+func undoEdit(s *store.Store, id string) tea.Cmd {
+	return func() tea.Msg {
+		_, err := s.Undo(id)
+		return textSavedMsg{id: id, undone: true, err: err}
+	}
+}
+
+// openInEditor suspends the TUI and edits a transcription in $EDITOR, saving it when the editor exits
+func openInEditor(s *store.Store, id, text string) tea.Cmd {
+	file, err := os.CreateTemp("", id+"-*.txt")
+	if err != nil {
+		return func() tea.Msg {
+			return textSavedMsg{id: id, err: fmt.Errorf("failed to create temporary file: %w", err)}
+		}
+	}
+	path := file.Name()
+	_, err = file.WriteString(text)
+	file.Close()
+	if err != nil {
+		os.Remove(path)
+		return func() tea.Msg {
+			return textSavedMsg{id: id, err: fmt.Errorf("failed to write temporary file: %w", err)}
+		}
+	}
+
+	// $EDITOR may include arguments, e.g. "code --wait"
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(path)
+		if err != nil {
+			return textSavedMsg{id: id, err: fmt.Errorf("editor exited with an error: %w", err)}
+		}
+		edited, err := os.ReadFile(path)
+		if err != nil {
+			return textSavedMsg{id: id, err: fmt.Errorf("failed to read edited text: %w", err)}
+		}
+		return textSavedMsg{id: id, err: s.SaveText(id, string(edited))}
+	})
+}
+
+// textSavedStatus describes the outcome of an edit or undo
+func textSavedStatus(msg textSavedMsg) string {
+	switch {
+	case errors.Is(msg.err, store.ErrNoRevisions):
+		return "Nothing to undo"
+	case msg.err != nil:
+		return errorStyle.Render(fmt.Sprintf("Failed to save: %v", msg.err))
+	case msg.undone:
+		return successStyle.Render("Restored the previous version ✓")
+	default:
+		return successStyle.Render("Saved ✓")
+	}
+}
+
+// editHelp is shown while the built-in editor is open
+var editHelp = []key.Binding{
+	key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("<ctrl+s>", "Save")),
+	key.NewBinding(key.WithKeys("esc"), key.WithHelp("<esc>", "Discard changes")),
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"lazywhisper/audio"
+	"lazywhisper/store"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newTestModel returns a model listing transcriptions saved with the given
+// ids and texts, newest first like the real list
+func newTestModel(t *testing.T, texts map[string]string) model {
+	t.Helper()
+	s, err := store.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, text := range texts {
+		if err := s.Save(id+".wav", &audio.TranscriptionResponse{Text: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model{store: s, transcriptionFiles: entries}
+}
+
+func TestEditSavesToTheTranscriptionOpened(t *testing.T) {
+	tests := []struct {
+		name   string
+		reload func(entries []store.Entry) []store.Entry
+	}{
+		{"new transcription shifts the list", func(entries []store.Entry) []store.Entry {
+			return append([]store.Entry{{ID: "2024-03-01-10-00-00"}}, entries...)
+		}},
+		{"list emptied", func([]store.Entry) []store.Entry {
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t, map[string]string{
+				"2024-01-01-10-00-00": "older",
+				"2024-02-01-10-00-00": "newer",
+			})
+			m.selectedContent = "newer"
+			m, _ = m.startEditing()
+			m.editor.SetValue("edited")
+
+			// A watch completion or sync pull reloads the list while editing
+			m.transcriptionFiles = tt.reload(m.transcriptionFiles)
+			_, cmd := m.handleEditUpdate(tea.KeyMsg{Type: tea.KeyCtrlS})
+			if cmd == nil {
+				t.Fatal("ctrl+s didn't save")
+			}
+			msg := cmd().(textSavedMsg)
+			if msg.err != nil || msg.id != "2024-02-01-10-00-00" {
+				t.Fatalf("saved %q (%v), want the transcription that was opened", msg.id, msg.err)
+			}
+			for id, want := range map[string]string{"2024-01-01-10-00-00": "older", "2024-02-01-10-00-00": "edited"} {
+				if got, _ := m.store.Text(id); got != want {
+					t.Errorf("text of %s = %q, want %q", id, got, want)
+				}
+			}
+		})
+	}
+}
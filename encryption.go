@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"lazywhisper/config"
+	"lazywhisper/crypt"
+	"lazywhisper/store"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// openStore opens the transcription store, unlocking it first when encryption is enabled
+func openStore(settings *config.Settings) (*store.Store, error) {
+	cipher, err := unlock(settings.Encryption)
+	if err != nil {
+		return nil, err
+	}
+	return store.OpenDefault(cipher)
+}
+
+// unlock derives the encryption key from the key file or passphrase. It returns
+// nil when encryption is off, which keeps files in plaintext.
+func unlock(settings config.EncryptionSettings) (*crypt.Cipher, error) {
+	if !settings.Enabled {
+		return nil, nil
+	}
+
+	secret, err := readSecret(settings)
+	if err != nil {
+		return nil, err
+	}
+	appDataDir, err := config.GetAppDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return crypt.Unlock(appDataDir, secret)
+}
+
+// readSecret reads the key file, or the passphrase from the environment or the terminal
+func readSecret(settings config.EncryptionSettings) ([]byte, error) {
+	if settings.KeyFile != "" {
+		secret, err := os.ReadFile(settings.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		return secret, nil
+	}
+	if passphrase := os.Getenv(settings.PassphraseEnv); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("encryption is enabled: set %s or encryption.key_file in %s", settings.PassphraseEnv, config.SettingsFile)
+	}
+	fmt.Fprint(os.Stderr, "Passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return passphrase, nil
+}
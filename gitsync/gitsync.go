@@ -0,0 +1,266 @@
+package gitsync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"lazywhisper/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ignored keeps files that are still being written out of commits
+const ignored = "*.tmp\n*.part\n"
+
+// Strategy decides what happens to files changed on both sides since the last sync
+type Strategy int
+
+const (
+	// Abort leaves both sides alone and returns a ConflictError
+	Abort Strategy = iota
+	// KeepLocal keeps this machine's version of every conflicting file
+	KeepLocal
+	// KeepRemote takes the remote's version of every conflicting file
+	KeepRemote
+)
+
+// ConflictError lists the files changed here and on the remote since the last sync
+type ConflictError struct {
+	Files []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%d files were changed here and on the remote: %s", len(e.Files), strings.Join(e.Files, ", "))
+}
+
+// Result describes what a sync did
+type Result struct {
+	// Committed is set when local changes were committed before syncing
+	Committed bool
+	// Changed lists the files added, changed or removed by the pull, relative to the directory
+	Changed []string
+}
+
+// Repo keeps a directory in a git repository and syncs it with a remote using
+// the git command
+type Repo struct {
+	dir    string
+	remote string
+	branch string
+	mu     *sync.Mutex
+}
+
+// locks is shared by every Repo for the same directory, so the watcher and
+// the TUI never run git in the same repository at once
+var locks sync.Map
+
+// Open prepares dir for syncing, creating the repository and pointing its
+// origin at the configured remote
+func Open(dir string, settings config.SyncSettings) (*Repo, error) {
+	if settings.Remote == "" {
+		return nil, fmt.Errorf("no sync remote configured")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is not installed or not in PATH")
+	}
+
+	lock, _ := locks.LoadOrStore(filepath.Clean(dir), &sync.Mutex{})
+	r := &Repo{dir: dir, remote: settings.Remote, branch: settings.Branch, mu: lock.(*sync.Mutex)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); errors.Is(err, os.ErrNotExist) {
+		if _, err := r.git("init", "-q"); err != nil {
+			return nil, fmt.Errorf("failed to create repository: %w", err)
+		}
+		if _, err := r.git("symbolic-ref", "HEAD", "refs/heads/"+r.branch); err != nil {
+			return nil, fmt.Errorf("failed to create repository: %w", err)
+		}
+	}
+
+	gitignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(gitignore); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(gitignore, []byte(ignored), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write .gitignore: %w", err)
+		}
+	}
+
+	// Commits need an author even on machines where git was never set up
+	if email, _ := r.git("config", "user.email"); email == "" {
+		if _, err := r.git("config", "user.name", "lazywhisper"); err != nil {
+			return nil, err
+		}
+		if _, err := r.git("config", "user.email", "lazywhisper@localhost"); err != nil {
+			return nil, err
+		}
+	}
+
+	url, err := r.git("remote", "get-url", "origin")
+	switch {
+	case err != nil:
+		_, err = r.git("remote", "add", "origin", r.remote)
+	case url != r.remote:
+		_, err = r.git("remote", "set-url", "origin", r.remote)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to set the sync remote: %w", err)
+	}
+	return r, nil
+}
+
+// Dir returns the directory being synced
+func (r *Repo) Dir() string {
+	return r.dir
+}
+
+// Commit commits every change in the directory and reports whether there was anything to commit
+func (r *Repo) Commit(message string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.commit(message)
+}
+
+func (r *Repo) commit(message string) (bool, error) {
+	if _, err := r.git("add", "-A"); err != nil {
+		return false, fmt.Errorf("failed to commit: %w", err)
+	}
+	status, err := r.git("status", "--porcelain")
+	if err != nil {
+		return false, fmt.Errorf("failed to commit: %w", err)
+	}
+	if status == "" {
+		return false, nil
+	}
+	if _, err := r.git("commit", "-q", "-m", message); err != nil {
+		return false, fmt.Errorf("failed to commit: %w", err)
+	}
+	return true, nil
+}
+
+// Sync commits local changes, merges the remote branch and pushes the result.
+// When files were changed on both sides, strategy decides which version is
+// kept; with Abort nothing is merged and a *ConflictError is returned.
+func (r *Repo) Sync(strategy Strategy) (*Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &Result{}
+	committed, err := r.commit("Update transcriptions")
+	if err != nil {
+		return result, err
+	}
+	result.Committed = committed
+
+	if _, err := r.git("fetch", "-q", "origin"); err != nil {
+		return result, fmt.Errorf("failed to fetch: %w", err)
+	}
+
+	remoteBranch := "origin/" + r.branch
+	// The remote branch doesn't exist until the first push
+	if _, err := r.git("rev-parse", "-q", "--verify", remoteBranch); err == nil {
+		before, _ := r.git("rev-parse", "-q", "--verify", "HEAD")
+		if err := r.merge(remoteBranch, strategy); err != nil {
+			return result, err
+		}
+		after, _ := r.git("rev-parse", "-q", "--verify", "HEAD")
+		if before != after {
+			var changed string
+			if before == "" {
+				changed, err = r.git("ls-files")
+			} else {
+				changed, err = r.git("diff", "--name-only", before, after)
+			}
+			if err != nil {
+				return result, fmt.Errorf("failed to list pulled changes: %w", err)
+			}
+			result.Changed = lines(changed)
+		}
+	}
+
+	// Nothing to push in an empty repository
+	if _, err := r.git("rev-parse", "-q", "--verify", "HEAD"); err != nil {
+		return result, nil
+	}
+	if _, err := r.git("push", "-q", "origin", "HEAD:refs/heads/"+r.branch); err != nil {
+		return result, fmt.Errorf("failed to push: %w", err)
+	}
+	return result, nil
+}
+
+// merge merges ref into the current branch, resolving conflicts with strategy
+func (r *Repo) merge(ref string, strategy Strategy) error {
+	// Nothing has been committed here yet, so take the remote as it is
+	if _, err := r.git("rev-parse", "-q", "--verify", "HEAD"); err != nil {
+		if _, err := r.git("reset", "-q", "--hard", ref); err != nil {
+			return fmt.Errorf("failed to merge: %w", err)
+		}
+		return nil
+	}
+
+	_, mergeErr := r.git("merge", "-q", "--no-edit", "--allow-unrelated-histories", ref)
+	if mergeErr == nil {
+		return nil
+	}
+	unmerged, _ := r.git("diff", "--name-only", "--diff-filter=U")
+	conflicts := lines(unmerged)
+	if len(conflicts) == 0 || strategy == Abort {
+		r.git("merge", "--abort")
+		if len(conflicts) == 0 {
+			return fmt.Errorf("failed to merge: %w", mergeErr)
+		}
+		return &ConflictError{Files: conflicts}
+	}
+
+	side := "--ours"
+	if strategy == KeepRemote {
+		side = "--theirs"
+	}
+	for _, file := range conflicts {
+		if _, err := r.git("checkout", side, "--", file); err != nil {
+			// The chosen side deleted the file
+			if _, err := r.git("rm", "-q", "--", file); err != nil {
+				r.git("merge", "--abort")
+				return fmt.Errorf("failed to resolve %s: %w", file, err)
+			}
+		}
+	}
+	if _, err := r.git("add", "-A"); err != nil {
+		r.git("merge", "--abort")
+		return fmt.Errorf("failed to resolve conflicts: %w", err)
+	}
+	if _, err := r.git("commit", "-q", "--no-edit"); err != nil {
+		r.git("merge", "--abort")
+		return fmt.Errorf("failed to resolve conflicts: %w", err)
+	}
+	return nil
+}
+
+// git runs a git command in the directory and returns its trimmed output
+func (r *Repo) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	// Fail instead of waiting for credentials nobody can type in
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], message)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
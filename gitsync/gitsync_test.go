@@ -0,0 +1,211 @@
+package gitsync
+
+import (
+	"errors"
+	"lazywhisper/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newRemote creates an empty bare repository and ignores the user's git
+// config, so commits use the author Open sets up
+func newRemote(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	if out, err := exec.Command("git", "init", "-q", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare: %v: %s", err, out)
+	}
+	return remote
+}
+
+// openClone opens a new directory syncing with remote, like a second machine
+func openClone(t *testing.T, remote string) *Repo {
+	t.Helper()
+	r, err := Open(t.TempDir(), config.SyncSettings{Remote: remote, Branch: "main"})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return r
+}
+
+func writeFile(t *testing.T, r *Repo, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(r.Dir(), name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, r *Repo, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(r.Dir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func mustSync(t *testing.T, r *Repo) *Result {
+	t.Helper()
+	result, err := r.Sync(Abort)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	return result
+}
+
+func TestOpen(t *testing.T) {
+	remote := newRemote(t)
+	dir := t.TempDir()
+	r, err := Open(dir, config.SyncSettings{Remote: remote, Branch: "notes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if head, _ := r.git("symbolic-ref", "HEAD"); head != "refs/heads/notes" {
+		t.Errorf("HEAD = %q, want refs/heads/notes", head)
+	}
+	if url, _ := r.git("remote", "get-url", "origin"); url != remote {
+		t.Errorf("origin = %q, want %q", url, remote)
+	}
+	if email, _ := r.git("config", "user.email"); email == "" {
+		t.Error("no commit author was set up")
+	}
+	if content := readFile(t, r, ".gitignore"); content != ignored {
+		t.Errorf(".gitignore = %q, want %q", content, ignored)
+	}
+
+	// Opening again follows a changed remote
+	other := newRemote(t)
+	r, err = Open(dir, config.SyncSettings{Remote: other, Branch: "notes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url, _ := r.git("remote", "get-url", "origin"); url != other {
+		t.Errorf("origin after reopening = %q, want %q", url, other)
+	}
+
+	if _, err := Open(t.TempDir(), config.SyncSettings{Branch: "main"}); err == nil {
+		t.Error("Open() without a remote succeeded")
+	}
+}
+
+func TestCommit(t *testing.T) {
+	r := openClone(t, newRemote(t))
+
+	writeFile(t, r, "a.txt", "hello")
+	if committed, err := r.Commit("first"); err != nil || !committed {
+		t.Fatalf("Commit() = %v, %v, want a commit", committed, err)
+	}
+	if committed, err := r.Commit("nothing"); err != nil || committed {
+		t.Errorf("Commit() without changes = %v, %v, want nothing committed", committed, err)
+	}
+
+	// Files still being written are ignored
+	writeFile(t, r, "b.txt.tmp", "partial")
+	if committed, err := r.Commit("partial"); err != nil || committed {
+		t.Errorf("Commit() with a temp file = %v, %v, want nothing committed", committed, err)
+	}
+
+	if subject, _ := r.git("log", "-1", "--format=%s"); subject != "first" {
+		t.Errorf("last commit = %q, want %q", subject, "first")
+	}
+}
+
+func TestSyncPushesAndPulls(t *testing.T) {
+	remote := newRemote(t)
+	a := openClone(t, remote)
+	b := openClone(t, remote)
+
+	writeFile(t, a, "a.txt", "from a")
+	if result := mustSync(t, a); !result.Committed || result.Changed != nil {
+		t.Errorf("first Sync() = %+v, want a commit and nothing pulled", result)
+	}
+	if result := mustSync(t, a); result.Committed || result.Changed != nil {
+		t.Errorf("Sync() without changes = %+v, want nothing done", result)
+	}
+
+	// Both machines added the same .gitignore, so only a.txt is new here
+	result := mustSync(t, b)
+	if !reflect.DeepEqual(result.Changed, []string{"a.txt"}) {
+		t.Errorf("Sync() on a new machine pulled %v, want a.txt", result.Changed)
+	}
+	if got := readFile(t, b, "a.txt"); got != "from a" {
+		t.Errorf("a.txt = %q after pulling", got)
+	}
+
+	writeFile(t, b, "b.txt", "from b")
+	writeFile(t, a, "c.txt", "from a again")
+	mustSync(t, b)
+	// Changes on both sides to different files merge
+	if result := mustSync(t, a); !reflect.DeepEqual(result.Changed, []string{"b.txt"}) {
+		t.Errorf("Sync() pulled %v, want b.txt", result.Changed)
+	}
+	if result := mustSync(t, b); !reflect.DeepEqual(result.Changed, []string{"c.txt"}) {
+		t.Errorf("Sync() pulled %v, want c.txt", result.Changed)
+	}
+	if got := readFile(t, b, "c.txt"); got != "from a again" {
+		t.Errorf("c.txt = %q after pulling", got)
+	}
+}
+
+func TestSyncConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		wantErr  bool
+		want     string
+	}{
+		{"abort leaves both sides alone", Abort, true, "local"},
+		{"keep local", KeepLocal, false, "local"},
+		{"keep remote", KeepRemote, false, "remote"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote := newRemote(t)
+			other := openClone(t, remote)
+			r := openClone(t, remote)
+
+			writeFile(t, other, "shared.txt", "original")
+			mustSync(t, other)
+			mustSync(t, r)
+
+			writeFile(t, other, "shared.txt", "remote")
+			mustSync(t, other)
+			writeFile(t, r, "shared.txt", "local")
+
+			_, err := r.Sync(tt.strategy)
+			var conflict *ConflictError
+			if tt.wantErr {
+				if !errors.As(err, &conflict) || !reflect.DeepEqual(conflict.Files, []string{"shared.txt"}) {
+					t.Fatalf("Sync() error = %v, want a conflict on shared.txt", err)
+				}
+				if _, err := os.Stat(filepath.Join(r.Dir(), ".git", "MERGE_HEAD")); err == nil {
+					t.Error("the merge was left in progress")
+				}
+			} else if err != nil {
+				t.Fatalf("Sync() error = %v", err)
+			}
+
+			if got := readFile(t, r, "shared.txt"); got != tt.want {
+				t.Errorf("shared.txt = %q, want %q", got, tt.want)
+			}
+
+			// The resolution is pushed, so the other machine ends up with it too
+			if !tt.wantErr {
+				mustSync(t, other)
+				if got := readFile(t, other, "shared.txt"); got != tt.want {
+					t.Errorf("shared.txt on the other machine = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
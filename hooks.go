@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"lazywhisper/hooks"
+	"lazywhisper/store"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// hookLogSize is how many hook runs the log pane keeps
+const hookLogSize = 50
+
+type hookRunMsg hooks.Run
+
+var hookLogKey = key.NewBinding(
+	key.WithKeys("H"),
+	key.WithHelp("<H>", "Hook log"),
+)
+
+// hookLogHelp is shown while the hook log is open
+var hookLogHelp = []key.Binding{
+	keys.Up,
+	keys.Down,
+	key.NewBinding(key.WithKeys("esc", "H"), key.WithHelp("<esc>", "Close the log")),
+}
+
+// waitForHookRun blocks until a hook exits
+func waitForHookRun(r *hooks.Runner) tea.Cmd {
+	if r == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return hookRunMsg(<-r.Runs())
+	}
+}
+
+// transcriptionHookEnv describes a saved transcription to a hook. Encrypted
+// files are decrypted to temporary copies that are removed once the hook exits.
+func transcriptionHookEnv(s *store.Store, entry store.Entry) (hooks.Env, error) {
+	textFile, cleanupText, err := s.Cipher().TempPlaintext(s.TextPath(entry.ID))
+	if err != nil {
+		return hooks.Env{}, err
+	}
+	env := hooks.Env{
+		ID:       entry.ID,
+		TextFile: textFile,
+		Duration: entry.Duration,
+		Language: entry.Language,
+		Cleanup:  cleanupText,
+	}
+
+	if audioFile := s.AudioFile(entry); audioFile != "" {
+		plaintext, cleanupAudio, err := s.Cipher().TempPlaintext(audioFile)
+		if err != nil {
+			cleanupText()
+			return hooks.Env{}, err
+		}
+		env.AudioFile = plaintext
+		env.Cleanup = func() {
+			cleanupText()
+			cleanupAudio()
+		}
+	}
+	return env, nil
+}
+
+// logHookRun adds a finished hook to the log, newest first
+func (m model) logHookRun(run hooks.Run) model {
+	m.hookLog = append([]hooks.Run{run}, m.hookLog...)
+	if len(m.hookLog) > hookLogSize {
+		m.hookLog = m.hookLog[:hookLogSize]
+	}
+	// Keep the same run selected while the log is open
+	if m.showingHookLog && m.hookLogIndex > 0 {
+		m.hookLogIndex = min(m.hookLogIndex+1, len(m.hookLog)-1)
+	}
+	return m
+}
+
+// handleHookLogUpdate moves through the hook log
+func (m model) handleHookLogUpdate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Up):
+		m.hookLogIndex = max(m.hookLogIndex-1, 0)
+	case key.Matches(msg, keys.Down):
+		m.hookLogIndex = max(min(m.hookLogIndex+1, len(m.hookLog)-1), 0)
+	case key.Matches(msg, keys.Back), key.Matches(msg, hookLogKey):
+		m.showingHookLog = false
+	case key.Matches(msg, keys.Quit):
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// hookLogView lists recent hook runs with the output of the selected one
+func (m model) hookLogView() string {
+	if len(m.hookLog) == 0 {
+		return paddedStyle.Render("Hook log:\n\nNo hooks have run yet.\n\nPress ESC to go back")
+	}
+
+	var b strings.Builder
+	b.WriteString("Hook log:\n\n")
+	for i, run := range m.hookLog {
+		prefix := "  "
+		if i == m.hookLogIndex {
+			prefix = "▶ "
+		}
+		fmt.Fprintf(&b, "%s%s\n", prefix, hookRunSummary(run))
+	}
+
+	selected := m.hookLog[m.hookLogIndex]
+	fmt.Fprintf(&b, "\n$ %s\n\n", selected.Command)
+	if output := strings.TrimRight(selected.Output, "\n"); output != "" {
+		b.WriteString(output)
+	} else {
+		b.WriteString(helpStyle.Render("(no output)"))
+	}
+	return paddedStyle.Render(b.String())
+}
+
+// hookRunSummary describes a hook run on one line
+func hookRunSummary(run hooks.Run) string {
+	outcome := successStyle.Render("ok")
+	if run.Err != nil {
+		outcome = errorStyle.Render(run.Err.Error())
+	}
+	return fmt.Sprintf("%s  %-26s %s  %.1fs", run.Started.Format("15:04:05"), run.Event, outcome, run.Duration.Seconds())
+}
+
+// hookStatusLine reports the last hook run, or returns an empty string when no hooks are configured
+func (m model) hookStatusLine() string {
+	switch {
+	case m.hooks == nil:
+		return ""
+	case len(m.hookLog) == 0:
+		return "Hooks: none run yet (H for the log)"
+	case m.hookLog[0].Err != nil:
+		return fmt.Sprintf("Hook %s failed: %v (H for the log)", m.hookLog[0].Event, m.hookLog[0].Err)
+	default:
+		return fmt.Sprintf("Hook %s ran at %s (H for the log)", m.hookLog[0].Event, m.hookLog[0].Started.Format("15:04"))
+	}
+}
@@ -0,0 +1,204 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"lazywhisper/config"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxOutput caps how much of a hook's output is kept
+const maxOutput = 64 << 10
+
+// Event names a point at which a hook runs. The names match the settings keys.
+type Event string
+
+const (
+	RecordingStart        Event = "on_recording_start"
+	RecordingStop         Event = "on_recording_stop"
+	TranscriptionComplete Event = "on_transcription_complete"
+	Error                 Event = "on_error"
+)
+
+// Env describes what a hook is run for. Empty fields are left out of the
+// hook's environment.
+type Env struct {
+	ID        string
+	TextFile  string
+	AudioFile string
+	// Duration is the length of the recording in seconds
+	Duration float64
+	Language string
+	Error    string
+	// Cleanup runs once the hook has exited, e.g. to remove decrypted copies of the files
+	Cleanup func()
+}
+
+// Run is the outcome of running a hook
+type Run struct {
+	Event    Event
+	Command  string
+	Started  time.Time
+	Duration time.Duration
+	// Output is the combined stdout and stderr, cut off after 64 KB
+	Output string
+	Err    error
+}
+
+// Runner runs the configured hooks in the background and reports each run
+type Runner struct {
+	commands map[Event]string
+	timeout  time.Duration
+	runs     chan Run
+	wg       sync.WaitGroup
+}
+
+// New creates a runner for the hooks in settings. It returns nil when no hook
+// is configured; a nil Runner ignores every event.
+func New(settings config.HooksSettings) *Runner {
+	commands := map[Event]string{}
+	for event, command := range map[Event]string{
+		RecordingStart:        settings.OnRecordingStart,
+		RecordingStop:         settings.OnRecordingStop,
+		TranscriptionComplete: settings.OnTranscriptionComplete,
+		Error:                 settings.OnError,
+	} {
+		if command != "" {
+			commands[event] = command
+		}
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	return &Runner{
+		commands: commands,
+		timeout:  time.Duration(settings.TimeoutSeconds) * time.Second,
+		runs:     make(chan Run, 32),
+	}
+}
+
+// Has reports whether a hook is configured for event
+func (r *Runner) Has(event Event) bool {
+	if r == nil {
+		return false
+	}
+	_, ok := r.commands[event]
+	return ok
+}
+
+// Fire starts the hook for event without waiting for it. env.Cleanup runs
+// even when no hook is configured.
+func (r *Runner) Fire(event Event, env Env) {
+	if !r.Has(event) {
+		if env.Cleanup != nil {
+			env.Cleanup()
+		}
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		run := r.run(event, r.commands[event], env)
+		if env.Cleanup != nil {
+			env.Cleanup()
+		}
+		// Drop the report rather than hold up the hook when nobody is reading
+		select {
+		case r.runs <- run:
+		default:
+		}
+	}()
+}
+
+// Runs reports every hook once it has exited. It is nil for a nil Runner.
+func (r *Runner) Runs() <-chan Run {
+	if r == nil {
+		return nil
+	}
+	return r.runs
+}
+
+// Wait blocks until every hook started so far has exited
+func (r *Runner) Wait() {
+	if r != nil {
+		r.wg.Wait()
+	}
+}
+
+func (r *Runner) run(event Event, command string, env Env) Run {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), environment(event, env)...)
+	// Don't wait on background processes the hook left holding its output
+	cmd.WaitDelay = time.Second
+	output := &limitedBuffer{limit: maxOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	run := Run{Event: event, Command: command, Started: time.Now()}
+	err := cmd.Run()
+	run.Duration = time.Since(run.Started)
+	run.Output = output.String()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		run.Err = fmt.Errorf("timed out after %s", r.timeout)
+	case err != nil:
+		run.Err = err
+	}
+	return run
+}
+
+// environment returns the LAZYWHISPER_* variables for a hook
+func environment(event Event, env Env) []string {
+	vars := []string{"LAZYWHISPER_EVENT=" + string(event)}
+	add := func(name, value string) {
+		if value != "" {
+			vars = append(vars, name+"="+value)
+		}
+	}
+	add("LAZYWHISPER_ID", env.ID)
+	add("LAZYWHISPER_TEXT_FILE", env.TextFile)
+	add("LAZYWHISPER_AUDIO_FILE", env.AudioFile)
+	if env.Duration > 0 {
+		add("LAZYWHISPER_DURATION", strconv.FormatFloat(env.Duration, 'f', 1, 64))
+	}
+	add("LAZYWHISPER_LANGUAGE", env.Language)
+	add("LAZYWHISPER_ERROR", env.Error)
+	return vars
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the rest
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.limit - b.buf.Len(); room < len(p) {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
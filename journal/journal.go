@@ -0,0 +1,89 @@
+package journal
+
+import (
+	"fmt"
+	"lazywhisper/config"
+	"lazywhisper/store"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Journal appends transcriptions to a file per day
+type Journal struct {
+	path   string
+	format string
+}
+
+// New creates a journal from settings
+func New(settings config.JournalSettings) (*Journal, error) {
+	if settings.Path == "" {
+		return nil, fmt.Errorf("no journal path configured")
+	}
+	return &Journal{path: settings.Path, format: settings.Format}, nil
+}
+
+// Append adds a transcription to the journal file for the day it was recorded
+// and returns the file. The file is locked while writing so entries from
+// concurrent instances don't interleave.
+func (j *Journal) Append(entry store.Entry, text string) (string, error) {
+	// The title and text are left out of the path, where a / would add directories
+	path := fill(j.path, fields(entry))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	if err := lock(file); err != nil {
+		return "", fmt.Errorf("failed to lock journal: %w", err)
+	}
+	defer unlock(file)
+
+	// Separate the entry from whatever came before, including hand-written notes
+	values := fields(entry)
+	values["title"] = entry.Title
+	values["text"] = strings.TrimSpace(text)
+	content := fill(j.format, values)
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		content = "\n" + content
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if _, err := file.WriteString(content); err != nil {
+		return "", fmt.Errorf("failed to write journal: %w", err)
+	}
+	return path, nil
+}
+
+// fields returns the placeholder values that are safe in a path: date, time,
+// id, language and duration
+func fields(entry store.Entry) map[string]string {
+	return map[string]string{
+		"date":     entry.Created.Format("2006-01-02"),
+		"time":     entry.Created.Format("15:04"),
+		"id":       entry.ID,
+		"language": entry.Language,
+		"duration": formatDuration(entry.Duration),
+	}
+}
+
+// fill replaces the placeholders in template, written as {name} or {{name}}
+func fill(template string, values map[string]string) string {
+	var pairs []string
+	for name, value := range values {
+		pairs = append(pairs, "{{"+name+"}}", value, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+func formatDuration(seconds float64) string {
+	total := int(math.Round(seconds))
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
@@ -0,0 +1,53 @@
+package journal
+
+import (
+	"lazywhisper/config"
+	"lazywhisper/store"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendFillsPlaceholders(t *testing.T) {
+	entry := store.Entry{
+		ID:      "2024-03-01-09-30-00",
+		Title:   "a/b",
+		Created: time.Date(2024, 3, 1, 9, 30, 0, 0, time.Local),
+	}
+	tests := []struct {
+		name     string
+		path     string
+		wantFile string
+	}{
+		{"single braces", "{date}.md", "2024-03-01.md"},
+		{"double braces", "{{date}}.md", "2024-03-01.md"},
+		{"title and text stay out of the path", "{date} {title}{text}.md", "2024-03-01 {title}{text}.md"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			j, err := New(config.JournalSettings{
+				Path:   filepath.Join(dir, tt.path),
+				Format: "## {{time}} {title}\n{text}",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			path, err := j.Append(entry, " hello \n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(dir, tt.wantFile); path != want {
+				t.Errorf("Append() wrote %s, want %s", path, want)
+			}
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "## 09:30 a/b\nhello\n"; string(content) != want {
+				t.Errorf("journal = %q, want %q", content, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package journal
+
+import "os"
+
+// lock is a no-op where flock isn't available; appends are still written in a single call
+func lock(file *os.File) error {
+	return nil
+}
+
+func unlock(file *os.File) error {
+	return nil
+}
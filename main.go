@@ -4,15 +4,22 @@ package main
 // component library.
 
 import (
+	"context"
 	"fmt"
 	"lazywhisper/audio"
 	"lazywhisper/config"
+	"lazywhisper/gitsync"
+	"lazywhisper/hooks"
+	"lazywhisper/notes"
+	"lazywhisper/rewrite"
+	"lazywhisper/store"
+	"lazywhisper/subtitle"
+	"lazywhisper/watch"
 	"log"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
-	"sort"
 	"strings"
 	"syscall"
 	"time"
@@ -20,13 +27,14 @@ import (
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 const (
-	gap = "\n\n"
+	gap               = "\n\n"
 	setupInstructions = `Setup Required:
 
 OpenAI API Key Setup:
@@ -45,7 +53,7 @@ FFmpeg Setup:
 
 After setting up, restart the application.`
 
- smallMicrophone = `
+	smallMicrophone = `
      @@@@@@@
    @@@@@@@@@@@
    @@@@@@@@@@@
@@ -68,10 +76,10 @@ After setting up, restart the application.`
 )
 
 var (
-	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
-	paddedStyle = lipgloss.NewStyle().PaddingLeft(2)
+	errorStyle              = lipgloss.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
+	paddedStyle             = lipgloss.NewStyle().PaddingLeft(2)
 	successStyleWithPadding = lipgloss.NewStyle().Foreground(lipgloss.Color("2")).PaddingLeft(2)
-	successStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	successStyle            = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
 	// Match help style to the default color scheme
 	helpStyle = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{
 		Light: "#B2B2B2",
@@ -82,14 +90,71 @@ var (
 type recordingStartedMsg struct{}
 type recordingStoppedMsg struct{ err error }
 type transcriptionFinishedMsg struct {
-	text string
-	err  error
+	id          string
+	text        string
+	rawText     string
+	translation bool
+	redactions  int
+	err         error
 }
 
 type copyToClipboardMsg struct{ err error }
 
+type rewriteFinishedMsg struct {
+	id      string
+	profile string
+	err     error
+}
+
+type subtitlesExportedMsg struct {
+	paths []string
+	err   error
+}
+
 type tickMsg struct{}
 
+type watchEventMsg watch.Event
+
+type transcriptionsLoadedMsg []store.Entry
+
+// entryLabel is how an entry is shown in the transcription list
+func entryLabel(e store.Entry) string {
+	name := e.ID
+	var details []string
+	if e.Title != "" {
+		name = e.Title
+		details = append(details, e.Created.Format("Jan 2 15:04"))
+	}
+	if e.Starred {
+		name = "★ " + name
+	}
+	if e.Language != "" {
+		details = append(details, e.Language)
+	}
+	if e.Duration > 0 {
+		details = append(details, formatDuration(e.Duration))
+	}
+	if e.Translation {
+		details = append(details, "translated")
+	}
+	if e.Redactions > 0 {
+		details = append(details, fmt.Sprintf("%d redacted", e.Redactions))
+	}
+	for _, tag := range e.Tags {
+		details = append(details, "#"+tag)
+	}
+	if len(details) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s  %s", name, strings.Join(details, " · "))
+}
+
+// formatDuration renders seconds as m:ss
+func formatDuration(seconds float64) string {
+	total := int(seconds + 0.5)
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
 func checkDependencies() error {
 	// Check OpenAI API key
 	if os.Getenv("OPENAI_API_KEY") == "" {
@@ -105,6 +170,11 @@ func checkDependencies() error {
 }
 
 func main() {
+	// Subcommands run without the TUI
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	// Set up cleanup for when the program exits
 	setupCleanup()
 
@@ -118,16 +188,86 @@ func main() {
 	// Get OpenAI API key from environment
 	apiKey := os.Getenv("OPENAI_API_KEY")
 
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Error: %v", err)))
+		os.Exit(1)
+	}
+
+	transcriptions, err := openStore(settings)
+	if err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Error: %v", err)))
+		os.Exit(1)
+	}
+
+	hookRunner := hooks.New(settings.Hooks)
+	transcriber, saver, err := newTranscriber(apiKey, settings, transcriptions, hookRunner)
+	if err != nil {
+		fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Error: %v", err)))
+		os.Exit(1)
+	}
+
+	m := initialModel(transcriber, transcriptions, settings)
+	m.hooks = hookRunner
+	m.saver = saver
+	// Keep plaintext recordings out of the app data directory; the store
+	// encrypts them into it once they are transcribed
+	if settings.Encryption.Enabled {
+		dir, err := config.TempDir()
+		if err != nil {
+			fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			os.Exit(1)
+		}
+		m.recorder.SetOutputDir(dir)
+	}
+	if settings.Notes.Dir != "" {
+		if m.notes, err = notes.New(settings.Notes, transcriptions); err != nil {
+			m.err = err
+		}
+	}
+	if m.sync, err = openSync(settings.Sync, transcriptions); err != nil {
+		m.err = err
+	}
+	// A failed purge shouldn't stop the app; it is retried on the next start
+	if err := purgeExpiredTrash(transcriptions, settings.Trash); err != nil {
+		m.err = err
+	}
+	if _, _, err := applyRetention(transcriptions, settings.Retention); err != nil {
+		m.err = err
+	}
+
+	// Start the watch folder ingestion alongside the TUI if one is configured
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if settings.Watch.Dir != "" {
+		w, err := watch.New(settings, m.transcriber)
+		if err != nil {
+			fmt.Printf("\n%s\n\n", errorStyle.Render(fmt.Sprintf("Error: %v", err)))
+			os.Exit(1)
+		}
+		m.watcher = w
+		go w.Run(ctx)
+	}
+
 	p := tea.NewProgram(
-		initialModel(apiKey),
+		m,
 		tea.WithAltScreen(),       // Use alternate screen buffer
 		tea.WithMouseCellMotion(), // Enable mouse support
 	)
 
 	if _, err := p.Run(); err != nil {
+		m.player.Stop()
 		log.Fatal(err)
 	}
-	
+	m.player.Stop()
+
+	// Transcriptions made just before quitting still reach their webhooks;
+	// Ctrl+C quits without waiting
+	if saver.Pending() {
+		fmt.Println("Waiting for webhook deliveries (Ctrl+C to quit now)...")
+	}
+	saver.Wait()
+
 	audio.Cleanup()
 }
 
@@ -149,17 +289,37 @@ type (
 // keyMap defines a set of keybindings. To work for help it must satisfy
 // key.Map. It could also very easily be a map[string]key.Binding.
 type keyMap struct {
-	Record        key.Binding
-	StopRecording key.Binding
-	CopyToClip    key.Binding
+	Record             key.Binding
+	StopRecording      key.Binding
+	CopyToClip         key.Binding
 	ListTranscriptions key.Binding
-	Help          key.Binding
-	Back          key.Binding
-	Up            key.Binding
-	Down          key.Binding
-	Quit          key.Binding
-	Delete        key.Binding
-	Confirm       key.Binding
+	Help               key.Binding
+	Back               key.Binding
+	Up                 key.Binding
+	Down               key.Binding
+	Quit               key.Binding
+	Delete             key.Binding
+	Confirm            key.Binding
+	ExportSubtitles    key.Binding
+	CycleLanguage      key.Binding
+	ToggleTranslate    key.Binding
+	ToggleOriginal     key.Binding
+	Rewrite            key.Binding
+	Search             key.Binding
+	EditTitle          key.Binding
+	EditTags           key.Binding
+	ToggleStar         key.Binding
+	CycleFilter        key.Binding
+	Edit               key.Binding
+	OpenEditor         key.Binding
+	Undo               key.Binding
+	Play               key.Binding
+	SeekBack           key.Binding
+	SeekForward        key.Binding
+	Mark               key.Binding
+	MarkRange          key.Binding
+	Retranscribe       key.Binding
+	ExportNote         key.Binding
 }
 
 var keys = keyMap{
@@ -207,6 +367,86 @@ var keys = keyMap{
 		key.WithKeys("enter"),
 		key.WithHelp("<enter>", "Confirm"),
 	),
+	ExportSubtitles: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("<s>", "Export subtitles"),
+	),
+	CycleLanguage: key.NewBinding(
+		key.WithKeys("L"),
+		key.WithHelp("<L>", "Cycle language"),
+	),
+	ToggleTranslate: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("<t>", "Toggle translate to English"),
+	),
+	ToggleOriginal: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("<o>", "Toggle original/cleaned text"),
+	),
+	Rewrite: key.NewBinding(
+		key.WithKeys("w"),
+		key.WithHelp("<w>", "Rewrite with a profile"),
+	),
+	Search: key.NewBinding(
+		key.WithKeys("/"),
+		key.WithHelp("</>", "Search"),
+	),
+	EditTitle: key.NewBinding(
+		key.WithKeys("T"),
+		key.WithHelp("<T>", "Edit title"),
+	),
+	EditTags: key.NewBinding(
+		key.WithKeys("#"),
+		key.WithHelp("<#>", "Edit tags"),
+	),
+	ToggleStar: key.NewBinding(
+		key.WithKeys("*"),
+		key.WithHelp("<*>", "Star"),
+	),
+	CycleFilter: key.NewBinding(
+		key.WithKeys("f"),
+		key.WithHelp("<f>", "Filter starred/by tag"),
+	),
+	Edit: key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("<e>", "Edit text"),
+	),
+	OpenEditor: key.NewBinding(
+		key.WithKeys("E"),
+		key.WithHelp("<E>", "Edit in $EDITOR"),
+	),
+	Undo: key.NewBinding(
+		key.WithKeys("u"),
+		key.WithHelp("<u>", "Undo last edit"),
+	),
+	Play: key.NewBinding(
+		key.WithKeys("p"),
+		key.WithHelp("<p>", "Play/pause recording"),
+	),
+	SeekBack: key.NewBinding(
+		key.WithKeys("left", "["),
+		key.WithHelp("<←/[>", "Back 5s"),
+	),
+	SeekForward: key.NewBinding(
+		key.WithKeys("right", "]"),
+		key.WithHelp("<→/]>", "Forward 5s"),
+	),
+	Mark: key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("<space>", "Mark"),
+	),
+	MarkRange: key.NewBinding(
+		key.WithKeys("v"),
+		key.WithHelp("<v>", "Mark range"),
+	),
+	Retranscribe: key.NewBinding(
+		key.WithKeys("R"),
+		key.WithHelp("<R>", "Re-transcribe"),
+	),
+	ExportNote: key.NewBinding(
+		key.WithKeys("N"),
+		key.WithHelp("<N>", "Export Markdown note"),
+	),
 }
 
 type RecordingState int
@@ -219,89 +459,314 @@ const (
 )
 
 type model struct {
-	viewport       viewport.Model
-	help          help.Model
-	recordingState RecordingState
-	senderStyle   lipgloss.Style
-	err           error
-	recorder      *audio.Recorder
-	transcriber   *audio.Transcriber
-	transcription string
-	showCopied    bool
-	width         int
-	height        int
-	showingTranscriptions bool
-	transcriptionFiles    []string
-	selectedIndex        int
-	selectedContent      string
+	viewport                   viewport.Model
+	help                       help.Model
+	recordingState             RecordingState
+	senderStyle                lipgloss.Style
+	err                        error
+	recorder                   *audio.Recorder
+	transcriber                *audio.Transcriber
+	store                      *store.Store
+	transcription              string
+	transcriptionIsTranslation bool
+	transcriptionID            string
+	transcriptionRedactions    int
+	rawTranscription           string
+	showOriginal               bool
+	showCopied                 bool
+	width                      int
+	height                     int
+	showingTranscriptions      bool
+	transcriptionFiles         []store.Entry
+	allTranscriptions          []store.Entry
+	searchInput                textinput.Model
+	searching                  bool
+	listFilter                 string
+	editingField               string
+	fieldInput                 textinput.Model
+	editing                    bool
+	editor                     textarea.Model
+	// editingEntry is the transcription open in the editor or field prompt
+	editingEntry              store.Entry
+	player                    *audio.Player
+	playbackTicking           bool
+	selectedSegments          []audio.Segment
+	selectedIndex             int
+	selectedContent           string
+	selectedRawContent        string
+	selectedArtifacts         []rewrite.Artifact
 	showingDeleteConfirmation bool
+	lastTrashed               []string
+	undoGeneration            int
+	marked                    map[string]bool
+	rangeAnchor               int
+	bulk                      *bulkJob
+	notes                     *notes.Exporter
+	showingTrash              bool
+	trashEntries              []store.TrashedEntry
+	trashIndex                int
+	confirmingPurge           bool
+	purgeAfterDays            int
+	showingStorage            bool
+	storageUsage              []store.Usage
+	storageEvictions          []store.Eviction
+	retention                 config.RetentionSettings
+	sync                      *gitsync.Repo
+	syncInterval              time.Duration
+	syncing                   bool
+	syncErr                   error
+	syncConflicts             []string
+	showingSyncConflicts      bool
+	lastSync                  time.Time
+	hooks                     *hooks.Runner
+	hookLog                   []hooks.Run
+	showingHookLog            bool
+	hookLogIndex              int
+	saver                     *savingStore
+	recordingStartedAt        time.Time
+	statusMessage             string
+	languages                 []string
+	languageIndex             int
+	translate                 bool
+	watcher                   *watch.Watcher
+	watchStatus               watch.Status
+	watchErr                  error
+	rewriter                  *rewrite.Client
+	rewriteProfiles           []config.RewriteProfile
+	choosingRewrite           bool
+	latestArtifacts           []rewrite.Artifact
 }
 
-func loadTranscriptionContent(filename string) (string, error) {
-	appDataDir, err := config.GetAppDataDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get app data directory: %w", err)
+// displayedTranscription is the latest transcription, showing the original text when toggled
+func (m model) displayedTranscription() string {
+	if m.showOriginal && m.rawTranscription != "" {
+		return m.rawTranscription
 	}
+	return m.transcription
+}
 
-	transcriptionPath := filepath.Join(appDataDir, config.TranscriptionsDir, filename)
-	content, err := os.ReadFile(transcriptionPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read transcription: %w", err)
+// displayedContent is the selected transcription, showing the original text when toggled
+func (m model) displayedContent() string {
+	if m.showOriginal && m.selectedRawContent != "" {
+		return m.selectedRawContent
 	}
+	return m.selectedContent
+}
 
-	return string(content), nil
+// contentHeading labels which version of the text is being shown
+func (m model) contentHeading(heading string) string {
+	if m.showOriginal {
+		return heading + " (original)"
+	}
+	return heading
 }
 
-func loadTranscriptions() tea.Msg {
-	appDataDir, err := config.GetAppDataDir()
-	if err != nil {
-		return errMsg(fmt.Errorf("failed to get app data directory: %w", err))
+// selectTranscription moves the list selection to index and loads its text and rewrites
+func (m model) selectTranscription(index int) model {
+	m.selectedIndex = max(index, 0)
+	m.selectedContent = ""
+	m.selectedRawContent = ""
+	m.selectedArtifacts = nil
+	m.selectedSegments = nil
+	// Playback belongs to the transcription it was started from
+	if !m.playingSelected() {
+		m.player.Stop()
+	}
+	if m.selectedIndex >= len(m.transcriptionFiles) {
+		return m
 	}
 
-	transcriptionsPath := filepath.Join(appDataDir, config.TranscriptionsDir)
-	files, err := os.ReadDir(transcriptionsPath)
-	if err != nil {
-		return errMsg(fmt.Errorf("failed to read transcriptions directory: %w", err))
+	id := m.transcriptionFiles[m.selectedIndex].ID
+	if content, err := m.store.Text(id); err == nil {
+		m.selectedContent = content
+		// The text as the API returned it, kept only when post-processing changed it
+		if details, err := m.store.Details(id); err == nil {
+			m.selectedRawContent = details.RawText
+			m.selectedSegments = details.Segments
+		}
 	}
+	m.selectedArtifacts = loadArtifacts(m.store, id, m.rewriteProfiles)
+	return m
+}
 
-	var transcriptionFiles []string
-	for _, file := range files {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".txt" {
-			transcriptionFiles = append(transcriptionFiles, file.Name())
+// loadArtifacts returns the stored rewrites for a transcription, ignoring read errors
+func loadArtifacts(s *store.Store, id string, profiles []config.RewriteProfile) []rewrite.Artifact {
+	artifacts, _ := rewrite.Load(s.AppDataDir(), id, profiles, s.Cipher())
+	return artifacts
+}
+
+// renderArtifacts shows rewrites underneath the transcription they were made from
+func renderArtifacts(artifacts []rewrite.Artifact) string {
+	var b strings.Builder
+	for _, artifact := range artifacts {
+		b.WriteString("\n\n")
+		b.WriteString(successStyle.Render("── " + artifact.Profile + " ──"))
+		b.WriteString("\n")
+		b.WriteString(artifact.Text)
+	}
+	return b.String()
+}
+
+// runRewrite sends text through a rewrite profile and stores the result
+func runRewrite(client *rewrite.Client, s *store.Store, id string, profile config.RewriteProfile, text string) tea.Cmd {
+	return func() tea.Msg {
+		rewritten, err := client.Rewrite(profile, text)
+		if err != nil {
+			return rewriteFinishedMsg{id: id, profile: profile.Name, err: err}
 		}
+		if _, err := rewrite.Save(s.AppDataDir(), id, profile.Name, rewritten, s.Cipher()); err != nil {
+			return rewriteFinishedMsg{id: id, profile: profile.Name, err: err}
+		}
+		return rewriteFinishedMsg{id: id, profile: profile.Name}
 	}
+}
 
-	// Sort files in descending order (newest first)
-	sort.Slice(transcriptionFiles, func(i, j int) bool {
-		return transcriptionFiles[i] > transcriptionFiles[j]
-	})
+// rewriteMenuView lists the profiles that can be picked with the number keys
+func (m model) rewriteMenuView() string {
+	var b strings.Builder
+	b.WriteString("Rewrite with:\n\n")
+	for i, profile := range m.rewriteProfiles {
+		if i >= 9 {
+			break
+		}
+		fmt.Fprintf(&b, "%d. %s\n", i+1, profile.Name)
+	}
+	b.WriteString("\nPress 1-9 to choose or ESC to cancel")
+	return paddedStyle.Render(b.String())
+}
 
-	return transcriptionFiles
+// handleRewriteMenuUpdate picks a profile and runs it over the shown transcription
+func (m model) handleRewriteMenuUpdate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if key.Matches(msg, keys.Back) {
+		m.choosingRewrite = false
+		return m, nil
+	}
+
+	if len(msg.String()) != 1 {
+		return m, nil
+	}
+	choice := int(msg.String()[0]) - '1'
+	if choice < 0 || choice >= min(len(m.rewriteProfiles), 9) {
+		return m, nil
+	}
+	m.choosingRewrite = false
+	profile := m.rewriteProfiles[choice]
+	m.statusMessage = fmt.Sprintf("Rewriting with %s...", profile.Name)
+
+	if m.showingTranscriptions {
+		id := m.transcriptionFiles[m.selectedIndex].ID
+		return m, runRewrite(m.rewriter, m.store, id, profile, m.displayedContent())
+	}
+	return m, runRewrite(m.rewriter, m.store, m.transcriptionID, profile, m.displayedTranscription())
 }
 
-func initialModel(apiKey string) model {
+// loadTranscriptions lists the transcriptions in the store, newest first
+func loadTranscriptions(s *store.Store) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := s.List()
+		if err != nil {
+			return errMsg(err)
+		}
+		return transcriptionsLoadedMsg(entries)
+	}
+}
+
+func initialModel(transcriber *audio.Transcriber, transcriptions *store.Store, settings *config.Settings) model {
 	vp := viewport.New(0, 0)
 	vp.Style = lipgloss.NewStyle().PaddingTop(1)
 	h := help.New()
 
+	// Auto-detect is always available, followed by the configured languages.
+	// The session starts on the configured default.
+	languages := []string{""}
+	for _, language := range settings.Transcription.Languages {
+		if language != "" && !containsString(languages, language) {
+			languages = append(languages, language)
+		}
+	}
+	if !containsString(languages, settings.Transcription.Language) {
+		languages = append(languages, settings.Transcription.Language)
+	}
+	languageIndex := 0
+	for i, language := range languages {
+		if language == settings.Transcription.Language {
+			languageIndex = i
+		}
+	}
+
 	return model{
-		viewport:       vp,
-		recordingState: Idle,
-		senderStyle:    lipgloss.NewStyle().Foreground(lipgloss.Color("5")),
-		err:           nil,
-		help:          h,
-		recorder:      audio.NewRecorder(),
-		transcriber:   audio.NewTranscriber(apiKey),
-		showCopied:    false,
+		viewport:              vp,
+		recordingState:        Idle,
+		senderStyle:           lipgloss.NewStyle().Foreground(lipgloss.Color("5")),
+		err:                   nil,
+		help:                  h,
+		recorder:              audio.NewRecorder(),
+		transcriber:           transcriber,
+		store:                 transcriptions,
+		showCopied:            false,
 		showingTranscriptions: false,
-		transcriptionFiles: []string{},
-		selectedIndex: 0,
-		selectedContent: "",
+		transcriptionFiles:    []store.Entry{},
+		searchInput:           newSearchInput(),
+		rangeAnchor:           -1,
+		player:                newPlayer(settings.Playback, transcriptions),
+		purgeAfterDays:        settings.Trash.PurgeAfterDays,
+		retention:             settings.Retention,
+		syncInterval:          time.Duration(settings.Sync.IntervalMinutes) * time.Minute,
+		selectedIndex:         0,
+		selectedContent:       "",
+		languages:             languages,
+		languageIndex:         languageIndex,
+		rewriter:              rewrite.NewClient(settings.Rewrite, os.Getenv(settings.Rewrite.APIKeyEnv)),
+		rewriteProfiles:       settings.Rewrite.Profiles,
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// transcribeOptions returns the language and mode selected for this session
+func (m model) transcribeOptions() audio.TranscribeOptions {
+	return audio.TranscribeOptions{
+		Language:  m.languages[m.languageIndex],
+		Translate: m.translate,
 	}
 }
 
+// modeLine describes the selected language and whether we transcribe or translate
+func (m model) modeLine() string {
+	language := m.languages[m.languageIndex]
+	if language == "" {
+		language = "auto-detect"
+	}
+	if m.translate {
+		return fmt.Sprintf("Language: %s · Mode: translate to English", language)
+	}
+	return fmt.Sprintf("Language: %s · Mode: transcribe", language)
+}
+
 func (m model) Init() tea.Cmd {
-	return textarea.Blink
+	cmds := []tea.Cmd{textarea.Blink, waitForWatchEvent(m.watcher), waitForHookRun(m.hooks), waitForSaveWarning(m.saver)}
+	// Background sync starts right away and then runs on the interval
+	if m.sync != nil && m.syncInterval > 0 {
+		cmds = append(cmds, func() tea.Msg { return syncTickMsg{} })
+	}
+	return tea.Batch(cmds...)
+}
+
+// waitForWatchEvent blocks until the watcher reports progress
+func waitForWatchEvent(w *watch.Watcher) tea.Cmd {
+	if w == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return watchEventMsg(<-w.Events())
+	}
 }
 
 func startRecording(recorder *audio.Recorder) tea.Cmd {
@@ -323,14 +788,20 @@ func stopRecording(recorder *audio.Recorder) tea.Cmd {
 	}
 }
 
-func transcribe(recorder *audio.Recorder, transcriber *audio.Transcriber) tea.Cmd {
+func transcribe(recorder *audio.Recorder, transcriber *audio.Transcriber, opts audio.TranscribeOptions) tea.Cmd {
 	return func() tea.Msg {
 		audioFile := recorder.GetOutputFile()
-		text, err := transcriber.Transcribe(audioFile)
+		result, err := transcriber.Transcribe(audioFile, opts)
 		if err != nil {
 			return transcriptionFinishedMsg{err: err}
 		}
-		return transcriptionFinishedMsg{text: text}
+		return transcriptionFinishedMsg{
+			id:          store.IDFromPath(audioFile),
+			text:        result.Text,
+			rawText:     result.RawText,
+			translation: result.Translation,
+			redactions:  result.Redactions,
+		}
 	}
 }
 
@@ -359,6 +830,7 @@ func (m model) handleRecordingUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, keys.Record):
 			if m.recordingState == Idle || m.recordingState == TranscriptionComplete {
 				m.transcription = "" // Clear previous transcription when starting new recording
+				m.rawTranscription = ""
 				return m, startRecording(m.recorder)
 			}
 
@@ -366,13 +838,34 @@ func (m model) handleRecordingUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.recordingState == Recording {
 				return m, tea.Sequence(
 					stopRecording(m.recorder),
-					transcribe(m.recorder, m.transcriber),
+					transcribe(m.recorder, m.transcriber, m.transcribeOptions()),
 				)
 			}
 
 		case key.Matches(msg, keys.CopyToClip):
 			if m.transcription != "" && m.recordingState == TranscriptionComplete {
-				return m, copyToClipboard(m.transcription)
+				return m, copyToClipboard(m.displayedTranscription())
+			}
+
+		case key.Matches(msg, keys.ToggleOriginal):
+			if m.recordingState == TranscriptionComplete {
+				m.showOriginal = !m.showOriginal
+			}
+
+		case key.Matches(msg, keys.Rewrite):
+			if m.recordingState == TranscriptionComplete && m.transcription != "" && len(m.rewriteProfiles) > 0 {
+				m.choosingRewrite = true
+			}
+
+		// The language and mode are read when recording stops, so they can be changed mid-recording
+		case key.Matches(msg, keys.CycleLanguage):
+			if m.recordingState != Transcribing {
+				m.languageIndex = (m.languageIndex + 1) % len(m.languages)
+			}
+
+		case key.Matches(msg, keys.ToggleTranslate):
+			if m.recordingState != Transcribing {
+				m.translate = !m.translate
 			}
 		}
 	}
@@ -381,16 +874,56 @@ func (m model) handleRecordingUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) transcriptionListView() string {
+	if m.showingHookLog {
+		return m.hookLogView()
+	}
+
+	if m.choosingRewrite {
+		return m.rewriteMenuView()
+	}
+
+	if m.showingSyncConflicts {
+		return m.syncConflictsView()
+	}
+
+	if m.showingTrash {
+		return m.trashView()
+	}
+
+	if m.showingStorage {
+		return m.storageView()
+	}
+
 	if len(m.transcriptionFiles) == 0 {
-		return paddedStyle.Render("No transcriptions found.\n\nPress ESC to go back")
+		if m.searchActive() || m.listFilter != "" {
+			return paddedStyle.Render(m.searchLine() + "No matches.\n\nPress ESC to clear the search or filter")
+		}
+		if m.searching {
+			return paddedStyle.Render(m.searchLine())
+		}
+		return paddedStyle.Render("No transcriptions found.\n\nPress ESC to go back" + m.undoLine())
+	}
+
+	if m.editing {
+		return m.editorView()
+	}
+
+	if m.showingDeleteConfirmation && len(m.marked) > 0 {
+		return paddedStyle.Render(fmt.Sprintf(
+			"Move %d marked transcriptions and their recordings to trash?\n\nPress ENTER to confirm or ESC to cancel",
+			len(m.markedIDs()),
+		))
 	}
 
 	if m.showingDeleteConfirmation {
-		filename := m.transcriptionFiles[m.selectedIndex]
-		audioFilename := strings.TrimSuffix(filename, ".txt") + ".wav"
+		entry := m.transcriptionFiles[m.selectedIndex]
+		audioFilename := "none"
+		if entry.AudioPath != "" {
+			audioFilename = filepath.Base(entry.AudioPath)
+		}
 		confirmMsg := fmt.Sprintf(
-			"Are you sure you want to delete:\n• Transcription: %s\n• Audio: %s\n\nPress ENTER to confirm or ESC to cancel",
-			filename,
+			"Move to trash:\n• Transcription: %s\n• Audio: %s\n\nPress ENTER to confirm or ESC to cancel",
+			entry.ID,
 			audioFilename,
 		)
 		return paddedStyle.Render(confirmMsg)
@@ -399,51 +932,67 @@ func (m model) transcriptionListView() string {
 	// Create two-pane view
 	var leftPane strings.Builder
 	leftPane.WriteString("Transcriptions:\n\n")
-	
+	leftPane.WriteString(m.searchLine())
+	leftPane.WriteString(m.markLine())
+
 	// Calculate the width needed for the longest filename
 	maxWidth := len("Transcriptions:") // minimum width
 	for _, file := range m.transcriptionFiles {
-		if len(file) > maxWidth {
-			maxWidth = len(file)
+		if width := lipgloss.Width(entryLabel(file)); width > maxWidth {
+			maxWidth = width
 		}
 	}
-	// Add padding for the prefix (2 chars) and some buffer space
-	leftWidth := maxWidth + 6
+	// Add padding for the cursor and mark prefixes (4 chars) and some buffer space
+	leftWidth := maxWidth + 8
 
 	// If window is too narrow, only show the selected content
 	const minWidthForSidebar = 100
 	if m.width < minWidthForSidebar {
 		if len(m.transcriptionFiles) > 0 {
-			content := fmt.Sprintf("Selected Transcription (%d/%d):\n\n%s", 
-				m.selectedIndex+1, 
-				len(m.transcriptionFiles), 
-				m.selectedContent,
+			content := m.searchLine() + m.markLine() + fmt.Sprintf("%s:\n\n%s%s",
+				m.contentHeading(fmt.Sprintf("Selected Transcription (%d/%d)", m.selectedIndex+1, len(m.transcriptionFiles))),
+				m.playbackLine(),
+				m.selectedBody(),
 			)
+			content += renderArtifacts(m.selectedArtifacts)
 			if m.showCopied {
 				content += "\n\n" + successStyle.Render("Copied to clipboard! ✓")
 			}
+			if m.statusMessage != "" {
+				content += "\n\n" + m.statusMessage
+			}
+			content += m.undoLine()
 			return paddedStyle.Render(content)
 		}
 		return paddedStyle.Render("No transcriptions found.\n\nPress ESC to go back")
 	}
-	
+
 	for i, file := range m.transcriptionFiles {
 		prefix := "  "
 		if i == m.selectedIndex {
 			prefix = "▶ "
 		}
+		mark := "  "
+		if m.marked[file.ID] {
+			mark = "✓ "
+		}
 		// No need to truncate since we're using the natural width
-		leftPane.WriteString(fmt.Sprintf("%s%s\n", prefix, file))
+		leftPane.WriteString(fmt.Sprintf("%s%s%s\n", prefix, mark, entryLabel(file)))
 	}
-	
+
 	// Create right pane with selected content
-	rightPane := fmt.Sprintf("Selected Transcription:\n\n%s", m.selectedContent)
-	
+	rightPane := fmt.Sprintf("%s:\n\n%s%s", m.contentHeading("Selected Transcription"), m.playbackLine(), m.selectedBody())
+	rightPane += renderArtifacts(m.selectedArtifacts)
+
 	// Add copy confirmation if needed
 	if m.showCopied {
 		rightPane += "\n\n" + successStyle.Render("Copied to clipboard! ✓")
 	}
-	
+	if m.statusMessage != "" {
+		rightPane += "\n\n" + m.statusMessage
+	}
+	rightPane += m.undoLine()
+
 	// Style the panes
 	leftPaneStyled := lipgloss.NewStyle().
 		Width(leftWidth).
@@ -451,33 +1000,27 @@ func (m model) transcriptionListView() string {
 		BorderStyle(lipgloss.RoundedBorder()).
 		BorderRight(true).
 		Render(leftPane.String())
-	
+
 	rightPaneStyled := lipgloss.NewStyle().
 		Width(m.width - leftWidth - 5). // Account for border and some padding
 		PaddingLeft(2).
 		Render(rightPane)
-	
+
 	return lipgloss.JoinHorizontal(lipgloss.Top, leftPaneStyled, rightPaneStyled)
 }
 
-func deleteTranscription(filename string) tea.Cmd {
+// exportSubtitles writes SRT and WebVTT files for a transcription into the subtitles directory
+func exportSubtitles(s *store.Store, id string) tea.Cmd {
 	return func() tea.Msg {
-		appDataDir, err := config.GetAppDataDir()
-		if err != nil {
-			return errMsg(fmt.Errorf("failed to get app data directory: %w", err))
-		}
-
-		transcriptionPath := filepath.Join(appDataDir, config.TranscriptionsDir, filename)
-		if err := os.Remove(transcriptionPath); err != nil {
-			return errMsg(fmt.Errorf("failed to delete transcription: %w", err))
+		var paths []string
+		for _, format := range []subtitle.Format{subtitle.SRT, subtitle.VTT} {
+			outPath := filepath.Join(s.AppDataDir(), config.SubtitlesDir, id+"."+string(format))
+			if err := writeSubtitles(s, id, format, outPath); err != nil {
+				return subtitlesExportedMsg{err: err}
+			}
+			paths = append(paths, outPath)
 		}
-
-		// Also delete the corresponding audio file
-		audioFilename := strings.TrimSuffix(filename, ".txt") + ".wav"
-		audioPath := filepath.Join(appDataDir, config.RecordingsDir, audioFilename)
-		_ = os.Remove(audioPath) // Ignore error as audio file might not exist
-
-		return loadTranscriptions()
+		return subtitlesExportedMsg{paths: paths}
 	}
 }
 
@@ -490,9 +1033,14 @@ func (m model) handleTranscriptionListUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.showingDeleteConfirmation {
 			switch {
 			case key.Matches(msg, keys.Confirm):
+				if ids := m.markedIDs(); len(ids) > 0 {
+					m.showingDeleteConfirmation = false
+					return m.bulkTrash(ids)
+				}
 				if len(m.transcriptionFiles) > 0 {
 					m.showingDeleteConfirmation = false
-					return m, deleteTranscription(m.transcriptionFiles[m.selectedIndex])
+					m.player.Stop()
+					return m, trashTranscription(m.store, m.transcriptionFiles[m.selectedIndex].ID)
 				}
 			case key.Matches(msg, keys.Back):
 				m.showingDeleteConfirmation = false
@@ -511,41 +1059,196 @@ func (m model) handleTranscriptionListUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case key.Matches(msg, keys.Record):
 			m.showingTranscriptions = false
+			m.player.Stop()
 			m.showCopied = false
 			m.transcription = "" // Clear previous transcription
+			m.rawTranscription = ""
 			m.recordingState = Idle // Ensure we're in Idle state
 			m.viewport.SetContent(m.recordingView())
 			return m, startRecording(m.recorder)
 
 		case key.Matches(msg, keys.Up):
 			if m.selectedIndex > 0 {
-				m.selectedIndex--
 				m.showCopied = false // Reset copy message when changing selection
-				if content, err := loadTranscriptionContent(m.transcriptionFiles[m.selectedIndex]); err == nil {
-					m.selectedContent = content
-					m.viewport.SetContent(m.transcriptionListView())
-				}
+				m = m.selectTranscription(m.selectedIndex - 1)
+				m.viewport.SetContent(m.transcriptionListView())
 			}
 
 		case key.Matches(msg, keys.Down):
 			if m.selectedIndex < len(m.transcriptionFiles)-1 {
-				m.selectedIndex++
 				m.showCopied = false // Reset copy message when changing selection
-				if content, err := loadTranscriptionContent(m.transcriptionFiles[m.selectedIndex]); err == nil {
-					m.selectedContent = content
-					m.viewport.SetContent(m.transcriptionListView())
-				}
+				m = m.selectTranscription(m.selectedIndex + 1)
+				m.viewport.SetContent(m.transcriptionListView())
 			}
 
+		case key.Matches(msg, keys.CopyToClip) && len(m.marked) > 0:
+			m.showCopied = false
+			return m.bulkCopy(m.markedIDs())
+
 		case key.Matches(msg, keys.CopyToClip):
 			if m.selectedContent != "" {
 				m.showCopied = false // Reset any previous copy message
-				return m, copyToClipboard(m.selectedContent)
+				return m, copyToClipboard(m.displayedContent())
+			}
+
+		case key.Matches(msg, keys.ToggleOriginal):
+			m.showOriginal = !m.showOriginal
+			m.viewport.SetContent(m.transcriptionListView())
+
+		case key.Matches(msg, keys.Mark):
+			if len(m.transcriptionFiles) > 0 {
+				m.showCopied = false
+				m = m.toggleMark()
+				m.viewport.SetContent(m.transcriptionListView())
+			}
+
+		case key.Matches(msg, keys.MarkRange):
+			if len(m.transcriptionFiles) > 0 {
+				m = m.markRange()
+				m.viewport.SetContent(m.transcriptionListView())
+			}
+
+		case key.Matches(msg, keys.ExportNote):
+			if m.bulk == nil {
+				return m.exportNotes(m.selectionIDs())
+			}
+
+		case key.Matches(msg, keys.Retranscribe):
+			if m.bulk == nil {
+				return m.retranscribe(m.selectionIDs())
+			}
+
+		case key.Matches(msg, keys.Rewrite):
+			if len(m.transcriptionFiles) > 0 && len(m.rewriteProfiles) > 0 {
+				m.choosingRewrite = true
+				m.viewport.SetContent(m.transcriptionListView())
+			}
+
+		case key.Matches(msg, keys.ExportSubtitles) && len(m.marked) > 0:
+			return m.bulkExport(m.markedIDs())
+
+		case key.Matches(msg, keys.ExportSubtitles):
+			if len(m.transcriptionFiles) > 0 {
+				m.statusMessage = ""
+				return m, exportSubtitles(m.store, m.transcriptionFiles[m.selectedIndex].ID)
+			}
+
+		case key.Matches(msg, keys.Search):
+			var cmd tea.Cmd
+			m, cmd = m.startSearch()
+			m.viewport.SetContent(m.transcriptionListView())
+			return m, cmd
+
+		case key.Matches(msg, keys.EditTitle):
+			if len(m.transcriptionFiles) > 0 {
+				var cmd tea.Cmd
+				m, cmd = m.startEditingField(titleField)
+				m.viewport.SetContent(m.transcriptionListView())
+				return m, cmd
+			}
+
+		case key.Matches(msg, keys.EditTags):
+			if len(m.transcriptionFiles) > 0 {
+				field := tagsField
+				if len(m.marked) > 0 {
+					field = addTagsField
+				}
+				var cmd tea.Cmd
+				m, cmd = m.startEditingField(field)
+				m.viewport.SetContent(m.transcriptionListView())
+				return m, cmd
+			}
+
+		case key.Matches(msg, keys.ToggleStar):
+			if len(m.transcriptionFiles) > 0 {
+				return m, m.toggleStar()
+			}
+
+		case key.Matches(msg, keys.Edit):
+			if len(m.transcriptionFiles) > 0 {
+				var cmd tea.Cmd
+				m, cmd = m.startEditing()
+				m.viewport.SetContent(m.transcriptionListView())
+				return m, cmd
+			}
+
+		case key.Matches(msg, keys.OpenEditor):
+			if len(m.transcriptionFiles) > 0 {
+				return m, openInEditor(m.store, m.transcriptionFiles[m.selectedIndex].ID, m.selectedContent)
+			}
+
+		case key.Matches(msg, keys.Undo):
+			if len(m.transcriptionFiles) > 0 {
+				return m, undoEdit(m.store, m.transcriptionFiles[m.selectedIndex].ID)
+			}
+
+		case key.Matches(msg, keys.Play):
+			if len(m.transcriptionFiles) > 0 {
+				var cmd tea.Cmd
+				m, cmd = m.togglePlayback()
+				m.viewport.SetContent(m.transcriptionListView())
+				return m, cmd
 			}
 
+		case key.Matches(msg, keys.SeekBack):
+			var cmd tea.Cmd
+			m, cmd = m.seekPlayback(-seekSeconds)
+			m.viewport.SetContent(m.transcriptionListView())
+			return m, cmd
+
+		case key.Matches(msg, keys.SeekForward):
+			var cmd tea.Cmd
+			m, cmd = m.seekPlayback(seekSeconds)
+			m.viewport.SetContent(m.transcriptionListView())
+			return m, cmd
+
+		case key.Matches(msg, trashKeys.Undo):
+			if len(m.lastTrashed) > 0 {
+				ids := m.lastTrashed
+				m.lastTrashed = nil
+				return m, restoreTranscriptions(m.store, ids...)
+			}
+
+		case key.Matches(msg, trashKeys.Open):
+			m.showingTrash = true
+			m.trashIndex = 0
+			m.trashEntries = nil
+			return m, loadTrash(m.store)
+
+		case key.Matches(msg, storageKeys.Open):
+			m.showingStorage = true
+			m.storageUsage = nil
+			m.storageEvictions = nil
+			m.statusMessage = ""
+			return m, loadStorage(m.store, m.retention)
+
+		case key.Matches(msg, syncKeys.Now) && m.sync != nil:
+			if len(m.syncConflicts) > 0 {
+				m.showingSyncConflicts = true
+				m.viewport.SetContent(m.transcriptionListView())
+				return m, nil
+			}
+			return m.startSync(gitsync.Abort)
+
+		case key.Matches(msg, keys.CycleFilter):
+			m = m.cycleListFilter()
+			m.viewport.SetContent(m.transcriptionListView())
+
+		// Esc clears marks, then a search or filter, before leaving the list
+		case key.Matches(msg, keys.Back) && (len(m.marked) > 0 || m.rangeAnchor >= 0):
+			m = m.clearMarks()
+			m.viewport.SetContent(m.transcriptionListView())
+
+		case key.Matches(msg, keys.Back) && (m.searchActive() || m.listFilter != ""):
+			m.searchInput.SetValue("")
+			m.listFilter = ""
+			m = m.applyFilters().selectTranscription(0)
+			m.viewport.SetContent(m.transcriptionListView())
+
 		case key.Matches(msg, keys.Back):
 			m.showingTranscriptions = false
-			m.showCopied = false // Reset copy message when going back
+			m.player.Stop()
+			m.showCopied = false    // Reset copy message when going back
 			m.recordingState = Idle // Ensure we're in Idle state
 			m.viewport.SetContent(m.recordingView())
 		}
@@ -555,12 +1258,24 @@ func (m model) handleTranscriptionListUpdate(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) recordingView() string {
+	if m.showingHookLog {
+		return m.hookLogView()
+	}
+
+	if m.choosingRewrite {
+		return m.rewriteMenuView()
+	}
+
 	var content string
 	switch m.recordingState {
 	case Recording:
-		content = paddedStyle.Render("Recording... Press SPACE to stop")
+		content = paddedStyle.Render(fmt.Sprintf("Recording... Press SPACE to stop\n\n%s", m.modeLine()))
 	case Transcribing:
-		content = paddedStyle.Render("Transcribing...")
+		if m.translate {
+			content = paddedStyle.Render("Translating...")
+		} else {
+			content = paddedStyle.Render("Transcribing...")
+		}
 	case Idle:
 		if m.err != nil {
 			content = paddedStyle.Render(fmt.Sprintf("Error: %v\nPress 'r' to start recording", m.err))
@@ -569,10 +1284,21 @@ func (m model) recordingView() string {
 			if m.width >= 30 && m.height >= 25 {
 				microphone = smallMicrophone
 			}
-			content = paddedStyle.Render(fmt.Sprintf("%sPress 'r' to start recording", microphone))
+			content = paddedStyle.Render(fmt.Sprintf("%sPress 'r' to start recording\n\n%s", microphone, m.modeLine()))
 		}
 	case TranscriptionComplete:
-		mainContent := fmt.Sprintf("Transcription complete:\n\n%s", m.transcription)
+		heading := "Transcription complete"
+		if m.transcriptionIsTranslation {
+			heading = "Translation complete"
+		}
+		mainContent := fmt.Sprintf("%s:\n\n%s", m.contentHeading(heading), m.displayedTranscription())
+		if m.transcriptionRedactions > 0 {
+			mainContent += "\n\n" + helpStyle.Render(fmt.Sprintf("%d sensitive item(s) redacted", m.transcriptionRedactions))
+		}
+		mainContent += renderArtifacts(m.latestArtifacts)
+		if m.statusMessage != "" {
+			mainContent += "\n\n" + m.statusMessage
+		}
 		if m.showCopied {
 			content = fmt.Sprintf(
 				"%s\n\n%s",
@@ -595,12 +1321,25 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Calculate the height available for content
 		helpHeight := lipgloss.Height(m.help.View(m))
 		verticalMarginHeight := 2 // top and bottom margins
+		if m.watcher != nil {
+			verticalMarginHeight++ // watch status line
+		}
+		if m.sync != nil {
+			verticalMarginHeight++ // sync status line
+		}
+		if m.hooks != nil {
+			verticalMarginHeight++ // hook status line
+		}
 
 		// Set viewport dimensions
 		m.viewport.Width = msg.Width
 		m.viewport.Height = msg.Height - helpHeight - verticalMarginHeight
 		m.width = msg.Width
 		m.height = msg.Height
+		if m.editing {
+			m.editor.SetWidth(max(m.width-4, 20))
+			m.editor.SetHeight(max(m.viewport.Height-4, 5))
+		}
 
 		// Update content based on current view
 		if m.showingTranscriptions {
@@ -612,24 +1351,44 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case recordingStartedMsg:
 		m.recordingState = Recording
 		m.err = nil
+		m.recordingStartedAt = time.Now()
+		m.hooks.Fire(hooks.RecordingStart, hooks.Env{AudioFile: m.recorder.GetOutputFile()})
 
 	case recordingStoppedMsg:
 		if msg.err != nil {
 			m.err = msg.err
 			m.recordingState = Idle
+			m.hooks.Fire(hooks.Error, hooks.Env{AudioFile: m.recorder.GetOutputFile(), Error: msg.err.Error()})
 		} else {
 			m.recordingState = Transcribing
+			m.hooks.Fire(hooks.RecordingStop, hooks.Env{
+				AudioFile: m.recorder.GetOutputFile(),
+				Duration:  time.Since(m.recordingStartedAt).Seconds(),
+			})
 		}
 
 	case transcriptionFinishedMsg:
 		m.recordingState = TranscriptionComplete
 		if msg.err != nil {
 			m.err = msg.err
+			m.hooks.Fire(hooks.Error, hooks.Env{AudioFile: m.recorder.GetOutputFile(), Error: msg.err.Error()})
 		} else {
 			m.transcription = msg.text
+			m.rawTranscription = msg.rawText
+			m.transcriptionIsTranslation = msg.translation
+			m.transcriptionID = msg.id
+			m.transcriptionRedactions = msg.redactions
+			m.latestArtifacts = nil
+
+			// Run the profiles marked as automatic over every new transcription
+			for _, profile := range m.rewriteProfiles {
+				if profile.Auto {
+					cmds = append(cmds, runRewrite(m.rewriter, m.store, msg.id, profile, msg.text))
+				}
+			}
 			// Reload transcription files after successful transcription
 			if m.showingTranscriptions {
-				return m, loadTranscriptions
+				cmds = append(cmds, loadTranscriptions(m.store))
 			}
 		}
 
@@ -641,25 +1400,237 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Batch(cmd, tea.Sequence(tick))
 		}
 
+	case subtitlesExportedMsg:
+		if msg.err != nil {
+			m.statusMessage = errorStyle.Render(fmt.Sprintf("Subtitle export failed: %v", msg.err))
+		} else {
+			m.statusMessage = successStyle.Render(fmt.Sprintf("Subtitles saved to %s ✓", strings.Join(msg.paths, ", ")))
+		}
+		m.viewport.SetContent(m.transcriptionListView())
+		return m, tick
+
+	case rewriteFinishedMsg:
+		if msg.err != nil {
+			m.statusMessage = errorStyle.Render(fmt.Sprintf("%s failed: %v", msg.profile, msg.err))
+		} else {
+			m.statusMessage = ""
+			if msg.id == m.transcriptionID {
+				m.latestArtifacts = loadArtifacts(m.store, msg.id, m.rewriteProfiles)
+			}
+			if m.showingTranscriptions && len(m.transcriptionFiles) > 0 && m.transcriptionFiles[m.selectedIndex].ID == msg.id {
+				m.selectedArtifacts = loadArtifacts(m.store, msg.id, m.rewriteProfiles)
+			}
+		}
+
 	case tickMsg:
 		m.showCopied = false
-
-	case []string:
-		m.transcriptionFiles = msg
-		if len(m.transcriptionFiles) > 0 {
-			if content, err := loadTranscriptionContent(m.transcriptionFiles[0]); err == nil {
-				m.selectedContent = content
+		m.statusMessage = ""
+
+	case watchEventMsg:
+		m.watchStatus = m.watcher.Status()
+		switch msg.Kind {
+		case watch.Failed:
+			m.watchErr = fmt.Errorf("%s: %w", filepath.Base(msg.Source), msg.Err)
+			m.hooks.Fire(hooks.Error, hooks.Env{AudioFile: msg.Source, Error: msg.Err.Error()})
+		case watch.Completed:
+			m.watchErr = nil
+			// Pick up the new transcription if the list is open
+			if m.showingTranscriptions {
+				return m, tea.Batch(loadTranscriptions(m.store), waitForWatchEvent(m.watcher))
 			}
 		}
+		return m, waitForWatchEvent(m.watcher)
+
+	case transcriptionsLoadedMsg:
+		m.allTranscriptions = msg
+		m = m.applyFilters()
+		// Stay on the same position, which may have moved past the end after a delete
+		m = m.selectTranscription(min(m.selectedIndex, len(m.transcriptionFiles)-1))
 		// Update viewport content immediately after loading files
 		m.viewport.SetContent(m.transcriptionListView())
 		return m, nil
 
+	case trashedMsg:
+		if msg.err != nil {
+			m.statusMessage = errorStyle.Render(fmt.Sprintf("Failed to delete: %v", msg.err))
+			m.viewport.SetContent(m.transcriptionListView())
+			return m, tick
+		}
+		var undoCmd tea.Cmd
+		m, undoCmd = m.offerUndo([]string{msg.id})
+		return m, tea.Batch(loadTranscriptions(m.store), undoCmd)
+
+	case bulkStepMsg:
+		var cmd tea.Cmd
+		m, cmd = m.handleBulkStep(msg)
+		m.viewport.SetContent(m.transcriptionListView())
+		return m, cmd
+
+	case undoExpiredMsg:
+		if m.undoGeneration == msg.generation {
+			m.lastTrashed = nil
+		}
+
+	case trashLoadedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.trashEntries = msg.entries
+		m.trashIndex = max(min(m.trashIndex, len(m.trashEntries)-1), 0)
+
+	case storageLoadedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.storageUsage = msg.usage
+		m.storageEvictions = msg.evictions
+
+	case retentionAppliedMsg:
+		if msg.err != nil {
+			m.statusMessage = errorStyle.Render(fmt.Sprintf("Failed to delete recordings: %v", msg.err))
+		} else {
+			m.statusMessage = successStyle.Render(fmt.Sprintf("Deleted %d recordings, freeing %s ✓", msg.removed, formatBytes(msg.freed)))
+		}
+		return m, tea.Batch(loadStorage(m.store, m.retention), loadTranscriptions(m.store))
+
+	case playbackTickMsg:
+		if m.player.File() == "" {
+			m.playbackTicking = false
+			return m, nil
+		}
+		if m.showingTranscriptions {
+			m.viewport.SetContent(m.transcriptionListView())
+		}
+		return m, playbackTick()
+
+	case playbackFailedMsg:
+		m.player.Stop()
+		m.statusMessage = errorStyle.Render(fmt.Sprintf("Playback failed: %v", msg.err))
+		m.viewport.SetContent(m.transcriptionListView())
+		return m, tick
+
+	case textSavedMsg:
+		m.statusMessage = textSavedStatus(msg)
+		m.viewport.SetContent(m.transcriptionListView())
+		if msg.err != nil {
+			return m, tick
+		}
+		message := "Edit " + msg.id
+		if msg.undone {
+			message = "Undo edit of " + msg.id
+		}
+		return m, tea.Batch(loadTranscriptions(m.store), tick, commitChanges(m.sync, message))
+
+	case hookRunMsg:
+		m = m.logHookRun(hooks.Run(msg))
+		return m, waitForHookRun(m.hooks)
+
+	case saveWarningMsg:
+		m.statusMessage = errorStyle.Render(fmt.Sprintf("Warning: %v", msg.err))
+		return m, tea.Batch(tick, waitForSaveWarning(m.saver))
+
+	case syncCommittedMsg:
+		if msg.err != nil {
+			m.syncErr = msg.err
+		}
+
+	case syncTickMsg:
+		var cmd tea.Cmd
+		m, cmd = m.startSync(gitsync.Abort)
+		return m, tea.Batch(cmd, syncTick(m.syncInterval))
+
+	case syncedMsg:
+		var cmd tea.Cmd
+		m, cmd = m.handleSynced(msg)
+		if m.showingTranscriptions {
+			m.viewport.SetContent(m.transcriptionListView())
+		}
+		return m, cmd
+
+	case metadataSavedMsg:
+		m.statusMessage = errorStyle.Render(fmt.Sprintf("Failed to save: %v", msg.err))
+		m.viewport.SetContent(m.transcriptionListView())
+		return m, tick
+
 	case errMsg:
 		m.err = msg
 		return m, nil
 
 	case tea.KeyMsg:
+		// The profile menu takes every key except quit
+		if m.choosingRewrite && !key.Matches(msg, keys.Quit) {
+			updated, cmd := m.handleRewriteMenuUpdate(msg)
+			m = updated.(model)
+			if m.showingTranscriptions {
+				m.viewport.SetContent(m.transcriptionListView())
+			} else {
+				m.viewport.SetContent(m.recordingView())
+			}
+			return m, cmd
+		}
+
+		// The hook log can be opened over any view
+		if m.showingHookLog && !key.Matches(msg, keys.Help) {
+			updated, cmd := m.handleHookLogUpdate(msg)
+			m = updated.(model)
+			if m.showingTranscriptions {
+				m.viewport.SetContent(m.transcriptionListView())
+			} else {
+				m.viewport.SetContent(m.recordingView())
+			}
+			return m, cmd
+		}
+
+		// Sync conflicts are resolved before anything else in the list
+		if m.showingSyncConflicts && !key.Matches(msg, keys.Help) {
+			updated, cmd := m.handleSyncConflictsUpdate(msg)
+			m = updated.(model)
+			m.viewport.SetContent(m.transcriptionListView())
+			return m, cmd
+		}
+
+		// The trash view has its own bindings
+		if m.showingTrash && !key.Matches(msg, keys.Help) {
+			updated, cmd := m.handleTrashUpdate(msg)
+			m = updated.(model)
+			m.viewport.SetContent(m.transcriptionListView())
+			return m, cmd
+		}
+
+		// So does the storage panel
+		if m.showingStorage && !key.Matches(msg, keys.Help) {
+			updated, cmd := m.handleStorageUpdate(msg)
+			m = updated.(model)
+			m.viewport.SetContent(m.transcriptionListView())
+			return m, cmd
+		}
+
+		// The editor takes every key while open
+		if m.editing {
+			updated, cmd := m.handleEditUpdate(msg)
+			m = updated.(model)
+			m.viewport.SetContent(m.transcriptionListView())
+			return m, cmd
+		}
+
+		// The title and tags prompt takes every key while editing
+		if m.editingField != "" {
+			updated, cmd := m.handleFieldUpdate(msg)
+			m = updated.(model)
+			m.viewport.SetContent(m.transcriptionListView())
+			return m, cmd
+		}
+
+		// The search input takes every key while typing
+		if m.searching {
+			updated, cmd := m.handleSearchUpdate(msg)
+			m = updated.(model)
+			m.viewport.SetContent(m.transcriptionListView())
+			return m, cmd
+		}
+
 		// Global key handlers
 		switch {
 		case key.Matches(msg, keys.Back) && m.help.ShowAll:
@@ -685,15 +1656,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, keys.Quit):
 			return m, tea.Quit
 
+		case key.Matches(msg, hookLogKey) && m.hooks != nil:
+			m.showingHookLog = true
+			m.hookLogIndex = 0
+			if m.showingTranscriptions {
+				m.viewport.SetContent(m.transcriptionListView())
+			} else {
+				m.viewport.SetContent(m.recordingView())
+			}
+			return m, nil
+
 		case key.Matches(msg, keys.ListTranscriptions):
 			m.showingTranscriptions = !m.showingTranscriptions
 			if m.showingTranscriptions {
 				m.selectedIndex = 0
 				m.selectedContent = ""
+				m.searchInput.SetValue("")
+				m.listFilter = ""
 				content := paddedStyle.Render("Loading transcriptions...\n\nPress ESC to go back")
 				m.viewport.SetContent(content)
-				return m, loadTranscriptions
+				return m, loadTranscriptions(m.store)
 			}
+			m.player.Stop()
 			return m, nil
 		}
 
@@ -710,6 +1694,22 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
+	// Keep the cursor of whichever input is focused blinking
+	if _, ok := msg.(tea.KeyMsg); !ok {
+		var inputCmd tea.Cmd
+		switch {
+		case m.editing:
+			m.editor, inputCmd = m.editor.Update(msg)
+		case m.editingField != "":
+			m.fieldInput, inputCmd = m.fieldInput.Update(msg)
+		case m.searching:
+			m.searchInput, inputCmd = m.searchInput.Update(msg)
+		}
+		if inputCmd != nil {
+			cmds = append(cmds, inputCmd)
+		}
+	}
+
 	// Set the viewport content based on current view
 	if m.showingTranscriptions {
 		m.viewport.SetContent(m.transcriptionListView())
@@ -724,14 +1724,35 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) ShortHelp() []key.Binding {
+	if m.showingHookLog {
+		return append(hookLogHelp, keys.Help)
+	}
 	if m.showingTranscriptions {
-		if m.showingDeleteConfirmation {
+		if m.editing {
+			return editHelp
+		}
+		if m.editingField != "" {
+			return fieldHelp
+		}
+		if m.searching {
+			return searchHelp
+		}
+		if m.showingDeleteConfirmation || m.confirmingPurge {
 			return []key.Binding{
 				keys.Confirm,
 				keys.Back,
 				keys.Help,
 			}
 		}
+		if m.showingSyncConflicts {
+			return append(syncConflictHelp, keys.Help)
+		}
+		if m.showingTrash {
+			return append(trashHelp, keys.Help)
+		}
+		if m.showingStorage {
+			return append(storageHelp, keys.Help)
+		}
 		if m.width < 100 {
 			return []key.Binding{
 				keys.Up,
@@ -743,6 +1764,7 @@ func (m model) ShortHelp() []key.Binding {
 			return []key.Binding{
 				keys.CopyToClip,
 				keys.Delete,
+				keys.Search,
 				keys.Help,
 			}
 		}
@@ -777,35 +1799,64 @@ func (m model) ShortHelp() []key.Binding {
 }
 
 func (m model) FullHelp() [][]key.Binding {
+	if m.showingHookLog {
+		return [][]key.Binding{hookLogHelp, {keys.Help, keys.Quit}}
+	}
 	if m.showingTranscriptions {
-		if m.showingDeleteConfirmation {
+		if m.editing {
+			return [][]key.Binding{editHelp}
+		}
+		if m.editingField != "" {
+			return [][]key.Binding{fieldHelp}
+		}
+		if m.searching {
+			return [][]key.Binding{searchHelp}
+		}
+		if m.showingDeleteConfirmation || m.confirmingPurge {
 			return [][]key.Binding{
 				{keys.Confirm, keys.Back}, // Confirmation actions
 				{keys.Help, keys.Quit},    // Global controls
 			}
 		}
+		if m.showingSyncConflicts {
+			return [][]key.Binding{syncConflictHelp, {keys.Help, keys.Quit}}
+		}
+		if m.showingTrash {
+			return [][]key.Binding{trashHelp, {keys.Help, keys.Quit}}
+		}
+		if m.showingStorage {
+			return [][]key.Binding{storageHelp, {keys.Help, keys.Quit}}
+		}
 		return [][]key.Binding{
-			{keys.Up, keys.Down, keys.Back, keys.CopyToClip, keys.Delete}, // Navigation and actions
-			{keys.Help, keys.Quit},                  // Global controls
+			{keys.Up, keys.Down, keys.Back, keys.CopyToClip, keys.Delete},          // Navigation and actions
+			{keys.ExportSubtitles, keys.ToggleOriginal, keys.Rewrite, keys.Search}, // Text actions
+			{keys.EditTitle, keys.EditTags, keys.ToggleStar, keys.CycleFilter},     // Organizing
+			{keys.Edit, keys.OpenEditor, keys.Undo},                                // Editing
+			{keys.Play, keys.SeekBack, keys.SeekForward},                           // Playback
+			{trashKeys.Undo, trashKeys.Open, storageKeys.Open, syncKeys.Now},       // Trash, storage and sync
+			{keys.Mark, keys.MarkRange, keys.Retranscribe, keys.ExportNote},        // Bulk actions
+			{keys.Help, keys.Quit}, // Global controls
 		}
 	}
 
 	switch m.recordingState {
 	case Recording:
 		return [][]key.Binding{
-			{keys.StopRecording},        // first column
-			{keys.Help, keys.Quit},      // second column
+			{keys.StopRecording, keys.CycleLanguage, keys.ToggleTranslate}, // first column
+			{keys.Help, keys.Quit}, // second column
 			{key.NewBinding(key.WithHelp("Note", "Recording will automatically stop after 20 minutes"))},
 		}
 	case TranscriptionComplete:
 		return [][]key.Binding{
-			{keys.Record, keys.CopyToClip, keys.ListTranscriptions}, // first column
-			{keys.Help, keys.Quit},                                  // second column
+			{keys.Record, keys.CopyToClip, keys.ListTranscriptions, keys.ToggleOriginal, keys.Rewrite}, // first column
+			{keys.CycleLanguage, keys.ToggleTranslate},                                                 // second column
+			{keys.Help, keys.Quit}, // third column
 		}
 	default:
 		return [][]key.Binding{
-			{keys.Record, keys.ListTranscriptions}, // first column
-			{keys.Help, keys.Quit},                // second column
+			{keys.Record, keys.ListTranscriptions},     // first column
+			{keys.CycleLanguage, keys.ToggleTranslate}, // second column
+			{keys.Help, keys.Quit},                     // third column
 			{key.NewBinding(key.WithHelp("Note", "Recording will automatically stop after 20 minutes"))},
 		}
 	}
@@ -816,20 +1867,49 @@ func (m model) View() string {
 
 	// Add top margin
 	b.WriteString("\n")
-	
+
 	// Add viewport content
 	b.WriteString(m.viewport.View())
-	
+
 	// Add bottom margin and help
 	b.WriteString("\n")
 
+	if status := m.watchStatusLine(); status != "" {
+		b.WriteString(helpStyle.Render(status))
+		b.WriteString("\n")
+	}
+	if status := m.syncStatusLine(); status != "" {
+		b.WriteString(helpStyle.Render(status))
+		b.WriteString("\n")
+	}
+	if status := m.hookStatusLine(); status != "" {
+		b.WriteString(helpStyle.Render(status))
+		b.WriteString("\n")
+	}
+
 	// Add warning if help is shown and we're in recording or idle state
-	if (m.help.ShowAll) {
+	if m.help.ShowAll {
 		b.WriteString(helpStyle.Render("Note: Recordings automatically stop after 20 minutes"))
 		b.WriteString("\n")
 	}
-	
+
 	b.WriteString(helpStyle.Render(m.help.View(m)))
 
 	return b.String()
 }
+
+// watchStatusLine summarizes the watch folder queue, or returns an empty string when not watching
+func (m model) watchStatusLine() string {
+	if m.watcher == nil {
+		return ""
+	}
+	status := fmt.Sprintf("Watching %s: %d queued, %d processing",
+		m.watcher.Dir(),
+		m.watchStatus.Queued,
+		m.watchStatus.Processing,
+	)
+	if m.watchErr != nil {
+		status += fmt.Sprintf(" (last error: %v)", m.watchErr)
+	}
+	return status
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"lazywhisper/store"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// starredFilter is the list filter showing only starred transcriptions. Any
+// other non-empty filter is a tag.
+const starredFilter = "*"
+
+// Fields edited from the transcription list
+const (
+	titleField = "title"
+	tagsField  = "tags"
+	// addTagsField adds tags to every marked transcription
+	addTagsField = "add-tags"
+)
+
+type metadataSavedMsg struct {
+	err error
+}
+
+// updateMetadata changes an entry's title, tags or star and reloads the list
+func updateMetadata(s *store.Store, id string, change func(*store.Entry)) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Update(id, change); err != nil {
+			return metadataSavedMsg{err: err}
+		}
+		return loadTranscriptions(s)()
+	}
+}
+
+// startEditingField opens the title or tags prompt for the selected transcription.
+// Untitled transcriptions start with a title suggested from their first sentence.
+// The entry is kept so a list reload while typing can't change what is updated.
+func (m model) startEditingField(field string) (model, tea.Cmd) {
+	entry := m.transcriptionFiles[m.selectedIndex]
+
+	input := textinput.New()
+	switch field {
+	case titleField:
+		input.Prompt = "Title: "
+		input.SetValue(entry.Title)
+		if entry.Title == "" {
+			input.SetValue(store.SuggestTitle(m.selectedContent))
+		}
+	case tagsField:
+		input.Prompt = "Tags: "
+		input.Placeholder = "comma separated"
+		input.SetValue(strings.Join(entry.Tags, ", "))
+	case addTagsField:
+		input.Prompt = fmt.Sprintf("Add tags to %d: ", len(m.markedIDs()))
+		input.Placeholder = "comma separated"
+	}
+	input.CursorEnd()
+
+	m.editingField = field
+	m.fieldInput = input
+	m.editingEntry = entry
+	return m, m.fieldInput.Focus()
+}
+
+// handleFieldUpdate edits the title or tags prompt. Enter saves and Esc cancels.
+func (m model) handleFieldUpdate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.editingField = ""
+		return m, nil
+	case "enter":
+		field, value := m.editingField, strings.TrimSpace(m.fieldInput.Value())
+		m.editingField = ""
+		if field == addTagsField {
+			return m.bulkTag(m.markedIDs(), store.ParseTags(value))
+		}
+		return m, updateMetadata(m.store, m.editingEntry.ID, func(e *store.Entry) {
+			if field == titleField {
+				e.Title = value
+			} else {
+				e.Tags = store.ParseTags(value)
+			}
+		})
+	}
+
+	var cmd tea.Cmd
+	m.fieldInput, cmd = m.fieldInput.Update(msg)
+	return m, cmd
+}
+
+// toggleStar stars or unstars the selected transcription
+func (m model) toggleStar() tea.Cmd {
+	entry := m.transcriptionFiles[m.selectedIndex]
+	return updateMetadata(m.store, entry.ID, func(e *store.Entry) {
+		e.Starred = !entry.Starred
+	})
+}
+
+// cycleListFilter moves to the next filter: everything, starred, then each tag
+func (m model) cycleListFilter() model {
+	filters := []string{"", starredFilter}
+	tags := map[string]bool{}
+	for _, entry := range m.allTranscriptions {
+		for _, tag := range entry.Tags {
+			tags[tag] = true
+		}
+	}
+	var sorted []string
+	for tag := range tags {
+		sorted = append(sorted, tag)
+	}
+	sort.Strings(sorted)
+	filters = append(filters, sorted...)
+
+	next := 0
+	for i, filter := range filters {
+		if filter == m.listFilter {
+			next = (i + 1) % len(filters)
+		}
+	}
+	m.listFilter = filters[next]
+	return m.applyFilters().selectTranscription(0)
+}
+
+func matchesListFilter(entry store.Entry, filter string) bool {
+	if filter == starredFilter {
+		return entry.Starred
+	}
+	return entry.HasTag(filter)
+}
+
+func listFilterLabel(filter string) string {
+	if filter == starredFilter {
+		return "starred"
+	}
+	return fmt.Sprintf("tagged #%s", filter)
+}
+
+// fieldHelp is shown while editing a title or tags
+var fieldHelp = []key.Binding{
+	key.NewBinding(key.WithKeys("enter"), key.WithHelp("<enter>", "Save")),
+	key.NewBinding(key.WithKeys("esc"), key.WithHelp("<esc>", "Cancel")),
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"lazywhisper/store"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestFieldEditUpdatesTheTranscriptionOpened(t *testing.T) {
+	m := newTestModel(t, map[string]string{
+		"2024-01-01-10-00-00": "older",
+		"2024-02-01-10-00-00": "newer",
+	})
+	m, _ = m.startEditingField(titleField)
+	m.fieldInput.SetValue("Standup")
+
+	m.transcriptionFiles = append([]store.Entry{{ID: "2024-03-01-10-00-00"}}, m.transcriptionFiles...)
+	_, cmd := m.handleFieldUpdate(tea.KeyMsg{Type: tea.KeyEnter})
+	if msg, ok := cmd().(metadataSavedMsg); ok {
+		t.Fatalf("update failed: %v", msg.err)
+	}
+
+	for id, want := range map[string]string{"2024-01-01-10-00-00": "", "2024-02-01-10-00-00": "Standup"} {
+		entry, err := m.store.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.Title != want {
+			t.Errorf("title of %s = %q, want %q", id, entry.Title, want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package notes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lazywhisper/config"
+	"lazywhisper/store"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ManifestFile records every exported note and the hash of what was written,
+// so notes edited by hand are never overwritten
+const ManifestFile = "notes_manifest.json"
+
+// Status describes what an export did with a note
+type Status string
+
+const (
+	Created   Status = "created"
+	Updated   Status = "updated"
+	Unchanged Status = "unchanged"
+	// Edited notes were changed by hand since they were exported and are left alone
+	Edited Status = "edited, skipped"
+	// Removed notes were deleted from the vault since they were exported and aren't recreated
+	Removed Status = "removed, skipped"
+)
+
+// Result is the outcome of exporting one transcription
+type Result struct {
+	ID     string
+	Path   string
+	Status Status
+}
+
+type exported struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+}
+
+// Exporter writes transcriptions as Markdown notes with YAML frontmatter
+type Exporter struct {
+	dir      string
+	filename string
+	store    *store.Store
+
+	mu       sync.Mutex
+	manifest string
+}
+
+// New creates an exporter writing into the vault directory from settings
+func New(settings config.NotesSettings, s *store.Store) (*Exporter, error) {
+	if settings.Dir == "" {
+		return nil, fmt.Errorf("no notes directory configured")
+	}
+	if err := os.MkdirAll(settings.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", settings.Dir, err)
+	}
+	return &Exporter{
+		dir:      settings.Dir,
+		filename: settings.Filename,
+		store:    s,
+		manifest: filepath.Join(s.AppDataDir(), ManifestFile),
+	}, nil
+}
+
+// Export writes the note for a transcription. A note written before is
+// updated in place, unless it was edited or removed since.
+func (e *Exporter) Export(id string) (Result, error) {
+	entry, err := e.store.Get(id)
+	if err != nil {
+		return Result{}, err
+	}
+	text, err := e.store.Text(id)
+	if err != nil {
+		return Result{}, err
+	}
+	content := Render(entry, text, e.store.AudioFile(entry))
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	manifest, err := e.loadManifest()
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{ID: id}
+	previous, ok := manifest[id]
+	if ok {
+		result.Path = previous.Path
+		current, err := os.ReadFile(previous.Path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			result.Status = Removed
+			return result, nil
+		case err != nil:
+			return Result{}, fmt.Errorf("failed to read %s: %w", previous.Path, err)
+		case hash(current) != previous.Hash:
+			result.Status = Edited
+			return result, nil
+		case hash(content) == previous.Hash:
+			result.Status = Unchanged
+			return result, nil
+		}
+		result.Status = Updated
+	} else {
+		// Name untitled notes after their first sentence rather than the date again
+		named := entry
+		if named.Title == "" {
+			named.Title = strings.TrimSuffix(store.SuggestTitle(text), "…")
+		}
+		result.Path = uniquePath(filepath.Join(e.dir, Filename(e.filename, named)+".md"))
+		result.Status = Created
+	}
+
+	if err := os.MkdirAll(filepath.Dir(result.Path), 0755); err != nil {
+		return Result{}, fmt.Errorf("failed to create %s: %w", filepath.Dir(result.Path), err)
+	}
+	if err := os.WriteFile(result.Path, content, 0644); err != nil {
+		return Result{}, fmt.Errorf("failed to write note: %w", err)
+	}
+	manifest[id] = exported{Path: result.Path, Hash: hash(content)}
+	return result, e.saveManifest(manifest)
+}
+
+// ExportAll exports every transcription, oldest first, stopping at the first error
+func (e *Exporter) ExportAll() ([]Result, error) {
+	entries, err := e.store.List()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		result, err := e.Export(entries[i].ID)
+		if err != nil {
+			return results, fmt.Errorf("failed to export %s: %w", entries[i].ID, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Render builds the note for a transcription: YAML frontmatter followed by the
+// title and text. audioFile is linked when the recording still exists.
+func Render(entry store.Entry, text, audioFile string) []byte {
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "id: %s\n", quote(entry.ID))
+	if entry.Title != "" {
+		fmt.Fprintf(&b, "title: %s\n", quote(entry.Title))
+	}
+	fmt.Fprintf(&b, "date: %s\n", entry.Created.Format("2006-01-02T15:04:05-07:00"))
+	if entry.Duration > 0 {
+		fmt.Fprintf(&b, "duration: %d\n", int(math.Round(entry.Duration)))
+	}
+	if entry.Language != "" {
+		fmt.Fprintf(&b, "language: %s\n", quote(entry.Language))
+	}
+	if len(entry.Tags) > 0 {
+		b.WriteString("tags:\n")
+		for _, tag := range entry.Tags {
+			fmt.Fprintf(&b, "  - %s\n", quote(tag))
+		}
+	}
+	if audioFile != "" {
+		link := url.URL{Scheme: "file", Path: filepath.ToSlash(audioFile)}
+		fmt.Fprintf(&b, "audio: %s\n", quote(link.String()))
+	}
+	b.WriteString("---\n\n")
+
+	title := entry.Title
+	if title == "" {
+		title = entry.ID
+	}
+	fmt.Fprintf(&b, "# %s\n\n%s\n", title, strings.TrimSpace(text))
+	return []byte(b.String())
+}
+
+// Filename fills in the filename template for entry. {date}, {time}, {id} and
+// {title} are replaced; untitled transcriptions use their id as the title.
+func Filename(template string, entry store.Entry) string {
+	title := entry.Title
+	if title == "" {
+		title = entry.ID
+	}
+	name := strings.NewReplacer(
+		"{date}", entry.Created.Format("2006-01-02"),
+		"{time}", entry.Created.Format("15-04"),
+		"{id}", sanitize(entry.ID),
+		"{title}", sanitize(title),
+	).Replace(template)
+	if strings.TrimSpace(name) == "" {
+		return sanitize(entry.ID)
+	}
+	return name
+}
+
+// sanitize removes characters that aren't allowed in file names or that note
+// apps treat as link syntax
+func sanitize(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|#^[]`, r) || r < ' ' {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(s)
+}
+
+// uniquePath returns path, or the first "name N.md" after it that doesn't
+// exist, so files we didn't write are never overwritten
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 2; ; n++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return path
+		}
+		path = fmt.Sprintf("%s %d%s", base, n, ext)
+	}
+}
+
+// quote writes s as a YAML double-quoted scalar, which JSON string syntax is a subset of
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (e *Exporter) loadManifest() (map[string]exported, error) {
+	manifest := map[string]exported{}
+	data, err := os.ReadFile(e.manifest)
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ManifestFile, err)
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestFile, err)
+	}
+	return manifest, nil
+}
+
+func (e *Exporter) saveManifest(manifest map[string]exported) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", ManifestFile, err)
+	}
+	tmp := e.manifest + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save %s: %w", ManifestFile, err)
+	}
+	if err := os.Rename(tmp, e.manifest); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to save %s: %w", ManifestFile, err)
+	}
+	return nil
+}
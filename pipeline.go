@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"lazywhisper/audio"
+	"lazywhisper/config"
+	"lazywhisper/hooks"
+	"lazywhisper/journal"
+	"lazywhisper/notes"
+	"lazywhisper/redact"
+	"lazywhisper/store"
+	"lazywhisper/textproc"
+	"lazywhisper/webhook"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newTranscriber creates a transcriber saving to transcriptions with the
+// vocabulary prompt and text post-processing from the settings applied. The
+// completion hook, if any, runs on runner. Failed follow-up steps are reported
+// on the returned store's Warnings.
+func newTranscriber(apiKey string, settings *config.Settings, transcriptions *store.Store, runner *hooks.Runner) (*audio.Transcriber, *savingStore, error) {
+	saver, err := newSavingStore(settings, transcriptions, runner)
+	if err != nil {
+		return nil, nil, err
+	}
+	transcriber := audio.NewTranscriber(apiKey, saver)
+	transcriber.SetVocabulary(settings.Transcription.Vocabulary)
+
+	replacer, err := textproc.NewReplacer(settings.Processing.Replacements)
+	if err != nil {
+		return nil, nil, err
+	}
+	pipeline := textproc.Pipeline{replacer}
+
+	// Commands run after the replacements so those can fix misheard command phrases
+	if settings.Processing.SpokenCommands.Enabled {
+		commands, err := textproc.NewCommandProcessor(settings.Processing.SpokenCommands.Commands)
+		if err != nil {
+			return nil, nil, err
+		}
+		pipeline = append(pipeline, commands)
+	}
+
+	// Clean up runs last so it also tidies text around inserted line breaks
+	if settings.Processing.Cleanup.Enabled {
+		pipeline = append(pipeline, textproc.NewCleaner(settings.Processing.Cleanup.Fillers))
+	}
+
+	transcriber.SetProcessor(pipeline)
+
+	if settings.Processing.Redaction.Enabled {
+		redactor, err := redact.New(settings.Processing.Redaction)
+		if err != nil {
+			return nil, nil, err
+		}
+		transcriber.SetRedactor(redactor, settings.Processing.Redaction.DeleteAudio)
+	}
+
+	return transcriber, saver, nil
+}
+
+// saveWarningsSize is how many follow-up failures are kept until they are read
+const saveWarningsSize = 16
+
+// savingStore runs follow-up steps, like exporting a note, for every
+// transcription once it is saved. A failed step doesn't fail the save, since
+// the transcription is already stored; it is reported on Warnings instead.
+type savingStore struct {
+	*store.Store
+	afterSave []afterSaveStep
+	warnings  chan error
+	// sender delivers webhooks in the background; nil without endpoints
+	sender *webhook.Sender
+}
+
+type afterSaveStep struct {
+	name string
+	run  func(entry store.Entry, text string) error
+}
+
+// newSavingStore wraps transcriptions with the follow-up steps enabled in the settings
+func newSavingStore(settings *config.Settings, transcriptions *store.Store, runner *hooks.Runner) (*savingStore, error) {
+	var steps []afterSaveStep
+	var sender *webhook.Sender
+	if settings.Notes.AutoExport {
+		exporter, err := notes.New(settings.Notes, transcriptions)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, afterSaveStep{"export the note", func(entry store.Entry, _ string) error {
+			_, err := exporter.Export(entry.ID)
+			return err
+		}})
+	}
+	if settings.Journal.Enabled {
+		j, err := journal.New(settings.Journal)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, afterSaveStep{"append to the journal", func(entry store.Entry, text string) error {
+			_, err := j.Append(entry, text)
+			return err
+		}})
+	}
+
+	if settings.Sync.Enabled {
+		repo, err := openSync(settings.Sync, transcriptions)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, afterSaveStep{"commit to git", func(entry store.Entry, _ string) error {
+			_, err := repo.Commit("Transcribe " + entry.ID)
+			return err
+		}})
+	}
+
+	if len(settings.Webhooks.Endpoints) > 0 {
+		var err error
+		if sender, err = webhook.New(settings.Webhooks, transcriptions); err != nil {
+			return nil, err
+		}
+		steps = append(steps, afterSaveStep{"queue the webhooks", func(entry store.Entry, text string) error {
+			return sender.Send(entry, text)
+		}})
+	}
+
+	// The hook runs last so it sees the note, journal and commit
+	if runner.Has(hooks.TranscriptionComplete) {
+		steps = append(steps, afterSaveStep{"run the completion hook", func(entry store.Entry, _ string) error {
+			env, err := transcriptionHookEnv(transcriptions, entry)
+			if err != nil {
+				return err
+			}
+			runner.Fire(hooks.TranscriptionComplete, env)
+			return nil
+		}})
+	}
+
+	return &savingStore{Store: transcriptions, afterSave: steps, warnings: make(chan error, saveWarningsSize), sender: sender}, nil
+}
+
+func (s *savingStore) Save(audioFile string, result *audio.TranscriptionResponse) error {
+	if err := s.Store.Save(audioFile, result); err != nil {
+		return err
+	}
+	if len(s.afterSave) == 0 {
+		return nil
+	}
+	entry, err := s.Store.Get(store.IDFromPath(audioFile))
+	if err != nil {
+		s.warn(fmt.Errorf("transcription saved, but failed to run the follow-up steps: %w", err))
+		return nil
+	}
+
+	// Every step runs even if an earlier one failed
+	for _, step := range s.afterSave {
+		if err := step.run(entry, result.Text); err != nil {
+			s.warn(fmt.Errorf("transcription %s saved, but failed to %s: %w", entry.ID, step.name, err))
+		}
+	}
+	return nil
+}
+
+// Pending reports whether webhook deliveries are still being attempted
+func (s *savingStore) Pending() bool {
+	return s.sender != nil && s.sender.Pending() > 0
+}
+
+// Wait blocks until every queued webhook delivery has succeeded or given up
+func (s *savingStore) Wait() {
+	if s.sender != nil {
+		s.sender.Wait()
+	}
+}
+
+// Warnings reports every follow-up step that failed
+func (s *savingStore) Warnings() <-chan error {
+	return s.warnings
+}
+
+// warn reports a failed step, dropping it rather than holding up the
+// transcription when nobody is reading
+func (s *savingStore) warn(err error) {
+	select {
+	case s.warnings <- err:
+	default:
+	}
+}
+
+// saveWarningMsg is a follow-up step that failed after a transcription was saved
+type saveWarningMsg struct{ err error }
+
+// waitForSaveWarning blocks until a follow-up step fails
+func waitForSaveWarning(s *savingStore) tea.Cmd {
+	if s == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		return saveWarningMsg{<-s.Warnings()}
+	}
+}
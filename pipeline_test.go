@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"lazywhisper/audio"
+	"lazywhisper/config"
+	"lazywhisper/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSavingStoreReportsFailedStepsAsWarnings(t *testing.T) {
+	dir := t.TempDir()
+	transcriptions, err := store.Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ran []string
+	s := &savingStore{
+		Store: transcriptions,
+		afterSave: []afterSaveStep{
+			{"export the note", func(entry store.Entry, _ string) error {
+				ran = append(ran, "note")
+				return errors.New("vault is read-only")
+			}},
+			{"append to the journal", func(entry store.Entry, text string) error {
+				ran = append(ran, "journal:"+text)
+				return nil
+			}},
+		},
+		warnings: make(chan error, saveWarningsSize),
+	}
+
+	recording := filepath.Join(dir, config.RecordingsDir, "2024-01-01-10-00-00.wav")
+	if err := os.WriteFile(recording, []byte("wav"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(recording, &audio.TranscriptionResponse{Text: "hello"}); err != nil {
+		t.Fatalf("Save() error = %v, want the failed step reported as a warning", err)
+	}
+
+	if strings.Join(ran, ",") != "note,journal:hello" {
+		t.Errorf("steps ran = %v, want every step", ran)
+	}
+	select {
+	case err := <-s.Warnings():
+		if !strings.Contains(err.Error(), "failed to export the note: vault is read-only") {
+			t.Errorf("warning = %v", err)
+		}
+	default:
+		t.Fatal("no warning for the failed step")
+	}
+	select {
+	case err := <-s.Warnings():
+		t.Errorf("unexpected second warning: %v", err)
+	default:
+	}
+
+	if _, err := transcriptions.Get("2024-01-01-10-00-00"); err != nil {
+		t.Errorf("transcription wasn't saved: %v", err)
+	}
+}
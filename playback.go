@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"lazywhisper/audio"
+	"lazywhisper/config"
+	"lazywhisper/store"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// seekSeconds is how far the seek keys move playback
+const seekSeconds = 5
+
+// progressWidth is the number of cells in the playback progress bar
+const progressWidth = 30
+
+type playbackTickMsg struct{}
+
+// newPlayer creates the recording player. Encrypted recordings are decrypted to
+// a temporary file outside the app data directory while they are loaded.
+func newPlayer(settings config.PlaybackSettings, s *store.Store) *audio.Player {
+	player := audio.NewPlayer(settings.Command)
+	player.SetPrepare(s.Cipher().TempPlaintext)
+	return player
+}
+
+type playbackFailedMsg struct {
+	err error
+}
+
+// playbackTick redraws the progress bar while audio is loaded
+func playbackTick() tea.Cmd {
+	return tea.Tick(200*time.Millisecond, func(time.Time) tea.Msg {
+		return playbackTickMsg{}
+	})
+}
+
+// togglePlayback plays the selected recording, or pauses and resumes it if it is already loaded
+func (m model) togglePlayback() (model, tea.Cmd) {
+	entry := m.transcriptionFiles[m.selectedIndex]
+	file := m.store.AudioFile(entry)
+	if file == "" {
+		m.statusMessage = "No recording for this transcription"
+		return m, tick
+	}
+
+	var err error
+	if m.player.File() == file {
+		err = m.player.TogglePause()
+	} else {
+		err = m.player.Play(file, entry.Duration)
+	}
+	if err != nil {
+		return m, func() tea.Msg { return playbackFailedMsg{err: err} }
+	}
+	return m.startPlaybackTicks()
+}
+
+// seekPlayback moves the loaded recording by delta seconds
+func (m model) seekPlayback(delta float64) (model, tea.Cmd) {
+	if m.player.File() == "" {
+		return m, nil
+	}
+	if err := m.player.Seek(delta); err != nil {
+		return m, func() tea.Msg { return playbackFailedMsg{err: err} }
+	}
+	return m.startPlaybackTicks()
+}
+
+// startPlaybackTicks starts redrawing the progress bar unless it is already being redrawn
+func (m model) startPlaybackTicks() (model, tea.Cmd) {
+	if m.playbackTicking {
+		return m, nil
+	}
+	m.playbackTicking = true
+	return m, playbackTick()
+}
+
+// playingSelected reports whether the player has the selected transcription's recording loaded
+func (m model) playingSelected() bool {
+	if len(m.transcriptionFiles) == 0 || m.player.File() == "" {
+		return false
+	}
+	return m.player.File() == m.store.AudioFile(m.transcriptionFiles[m.selectedIndex])
+}
+
+// playbackLine renders the play state, position and a progress bar
+func (m model) playbackLine() string {
+	if !m.playingSelected() {
+		return ""
+	}
+
+	state := "⏸"
+	if m.player.Playing() {
+		state = "▶"
+	}
+	position, duration := m.player.Position(), m.player.Duration()
+	if duration <= 0 {
+		return fmt.Sprintf("%s %s\n\n", state, formatDuration(position))
+	}
+
+	filled := int(float64(progressWidth) * position / duration)
+	filled = min(max(filled, 0), progressWidth)
+	bar := strings.Repeat("━", filled) + strings.Repeat("─", progressWidth-filled)
+	return fmt.Sprintf("%s %s %s %s\n\n", state, formatDuration(position), bar, formatDuration(duration))
+}
+
+// selectedBody is the text shown for the selected transcription. While its
+// recording is loaded and segment timings exist, the segment being played is
+// highlighted.
+func (m model) selectedBody() string {
+	if !m.playingSelected() || len(m.selectedSegments) == 0 || m.showOriginal {
+		return m.highlightMatches(m.displayedContent())
+	}
+
+	current := currentSegment(m.selectedSegments, m.player.Position())
+	parts := make([]string, len(m.selectedSegments))
+	for i, segment := range m.selectedSegments {
+		text := strings.TrimSpace(segment.Text)
+		if i == current {
+			text = highlightStyle.Render(text)
+		}
+		parts[i] = text
+	}
+	return strings.Join(parts, " ")
+}
+
+// currentSegment returns the index of the segment playing at position, or -1 between segments
+func currentSegment(segments []audio.Segment, position float64) int {
+	for i, segment := range segments {
+		if position >= segment.Start && position < segment.End {
+			return i
+		}
+	}
+	return -1
+}
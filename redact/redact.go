@@ -0,0 +1,188 @@
+package redact
+
+import (
+	"fmt"
+	"lazywhisper/config"
+	"regexp"
+	"strings"
+)
+
+const (
+	Email  = "email"
+	Phone  = "phone"
+	Card   = "card"
+	APIKey = "api_key"
+)
+
+// BuiltinDetectors are enabled when the config doesn't list any
+var BuiltinDetectors = []string{Email, Phone, Card, APIKey}
+
+var builtinPatterns = map[string]*regexp.Regexp{
+	Email: regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`),
+	// International numbers starting with + and at most one separator between
+	// digits, or national numbers grouped 3-3-4 like (555) 123-4567.
+	// phoneDigits checks the length.
+	Phone: regexp.MustCompile(`\+\d(?:[ .-]?(?:\(\d+\)|\d))+\b|(?:\(\d{3}\) ?|\b\d{3}[ .-])\d{3}[ .-]\d{4}\b`),
+	// A leading + is matched so cardNumber can leave international phone
+	// numbers to the phone detector
+	Card: regexp.MustCompile(`\+?\b\d(?:[ -]?\d){12,18}\b`),
+	APIKey: regexp.MustCompile(strings.Join([]string{
+		`\bsk-[A-Za-z0-9_-]{20,}`,
+		`\bAKIA[0-9A-Z]{16}\b`,
+		`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+		`\bxox[abprs]-[A-Za-z0-9-]{10,}`,
+		`\bAIza[0-9A-Za-z_-]{35}\b`,
+	}, "|")),
+}
+
+// genericKeyPattern finds long tokens; looksRandom decides whether they are secrets
+var genericKeyPattern = regexp.MustCompile(`\b[A-Za-z0-9_-]{32,}\b`)
+
+// detectorOrder runs the most specific detectors first so a card number isn't
+// reported as a phone number
+var detectorOrder = []string{Email, APIKey, Card, Phone}
+
+// Redactor masks personal data and secrets in text
+type Redactor struct {
+	detectors []detector
+	mask      string
+}
+
+type detector struct {
+	name    string
+	pattern *regexp.Regexp
+	// valid filters out matches the pattern can't rule out on its own
+	valid func(match string) bool
+}
+
+// New builds a redactor from the enabled built-in detectors and custom patterns
+func New(settings config.RedactionSettings) (*Redactor, error) {
+	enabled := settings.Detectors
+	if len(enabled) == 0 {
+		enabled = BuiltinDetectors
+	}
+	for _, name := range enabled {
+		if _, ok := builtinPatterns[name]; !ok {
+			return nil, fmt.Errorf("unknown redaction detector %q (expected one of %s)", name, strings.Join(BuiltinDetectors, ", "))
+		}
+	}
+
+	r := &Redactor{mask: settings.Mask}
+	if r.mask == "" {
+		r.mask = "[REDACTED]"
+	}
+
+	for _, name := range detectorOrder {
+		if !contains(enabled, name) {
+			continue
+		}
+		d := detector{name: name, pattern: builtinPatterns[name]}
+		switch name {
+		case Card:
+			d.valid = cardNumber
+		case Phone:
+			d.valid = phoneDigits
+		}
+		r.detectors = append(r.detectors, d)
+
+		// Keys without a known prefix are caught by how random they look
+		if name == APIKey {
+			r.detectors = append(r.detectors, detector{name: name, pattern: genericKeyPattern, valid: looksRandom})
+		}
+	}
+
+	// Custom patterns run before the built-ins so they take precedence on overlaps
+	var custom []detector
+	for i, c := range settings.Custom {
+		pattern, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %d (%q) is not valid: %w", i+1, c.Name, err)
+		}
+		name := c.Name
+		if name == "" {
+			name = "custom"
+		}
+		custom = append(custom, detector{name: name, pattern: pattern})
+	}
+	r.detectors = append(custom, r.detectors...)
+
+	return r, nil
+}
+
+// Redact masks every match in text and returns the number of items masked
+func (r *Redactor) Redact(text string) (string, int) {
+	count := 0
+	for _, d := range r.detectors {
+		text = d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.valid != nil && !d.valid(match) {
+				return match
+			}
+			count++
+			return strings.ReplaceAll(r.mask, "{type}", d.name)
+		})
+	}
+	return text, count
+}
+
+// cardNumber reports whether match is a card number rather than a phone number
+// with a country code
+func cardNumber(match string) bool {
+	return !strings.HasPrefix(match, "+") && luhnValid(match)
+}
+
+// luhnValid reports whether the digits in match pass the card number checksum
+func luhnValid(match string) bool {
+	var digits []int
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// phoneDigits reports whether match has as many digits as a phone number,
+// from a short number with its country code up to the 15 digit international
+// maximum
+func phoneDigits(match string) bool {
+	digits := 0
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 8 && digits <= 15
+}
+
+// looksRandom reports whether a long token mixes letters and digits, which
+// rules out long words and plain numbers. Hex strings like git commit hashes
+// and checksums are left alone.
+func looksRandom(match string) bool {
+	return strings.ContainsAny(match, "0123456789") &&
+		strings.ContainsAny(strings.ToLower(match), "abcdefghijklmnopqrstuvwxyz") &&
+		strings.Trim(strings.ToLower(match), "0123456789abcdef") != ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
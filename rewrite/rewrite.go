@@ -0,0 +1,184 @@
+package rewrite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lazywhisper/config"
+	"lazywhisper/crypt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Client sends text to an OpenAI-compatible chat completions endpoint
+type Client struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// NewClient creates a client for the endpoint in settings. The API key is sent
+// as a bearer token and may be empty for local servers.
+func NewClient(settings config.RewriteSettings, apiKey string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(settings.BaseURL, "/"),
+		apiKey:     apiKey,
+		model:      settings.Model,
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// Rewrite sends text to the chat completions endpoint with the profile's system prompt
+func (c *Client) Rewrite(profile config.RewriteProfile, text string) (string, error) {
+	model := profile.Model
+	if model == "" {
+		model = c.model
+	}
+
+	body, err := json.Marshal(chatRequest{
+		Model: model,
+		Messages: []chatMessage{
+			{Role: "system", Content: profile.SystemPrompt},
+			{Role: "user", Content: text},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return "", fmt.Errorf("API response contained no choices")
+	}
+
+	return strings.TrimSpace(result.Choices[0].Message.Content), nil
+}
+
+// Artifact is the stored result of running a profile over a transcription
+type Artifact struct {
+	Profile string
+	Text    string
+	Path    string
+}
+
+// Dir returns the directory holding the derived artifacts for a transcription
+func Dir(appDataDir, id string) string {
+	return filepath.Join(appDataDir, config.DerivedDir, id)
+}
+
+// Save stores the result of a profile for the transcription with the given id,
+// encrypted with c when it is not nil
+func Save(appDataDir, id, profile, text string, c *crypt.Cipher) (string, error) {
+	dir := Dir(appDataDir, id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	path := filepath.Join(dir, Slug(profile)+".txt")
+	if err := c.WriteFile(path, []byte(text), 0644); err != nil {
+		return "", fmt.Errorf("failed to save rewrite: %w", err)
+	}
+	return path, nil
+}
+
+// Load returns the artifacts stored for a transcription sorted by profile
+func Load(appDataDir, id string, profiles []config.RewriteProfile, c *crypt.Cipher) ([]Artifact, error) {
+	entries, err := os.ReadDir(Dir(appDataDir, id))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rewrites: %w", err)
+	}
+
+	// Show the configured name when the profile still exists
+	names := map[string]string{}
+	for _, profile := range profiles {
+		names[Slug(profile.Name)] = profile.Name
+	}
+
+	var artifacts []Artifact
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".txt" {
+			continue
+		}
+		path := filepath.Join(Dir(appDataDir, id), entry.Name())
+		content, err := c.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rewrite: %w", err)
+		}
+
+		slug := strings.TrimSuffix(entry.Name(), ".txt")
+		name, ok := names[slug]
+		if !ok {
+			name = slug
+		}
+		artifacts = append(artifacts, Artifact{Profile: name, Text: string(content), Path: path})
+	}
+
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].Profile < artifacts[j].Profile
+	})
+	return artifacts, nil
+}
+
+// Slug turns a profile name into a file name
+func Slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,145 @@
+package rewrite
+
+import (
+	"encoding/json"
+	"lazywhisper/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientRewriteRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		apiKey    string
+		profile   config.RewriteProfile
+		wantAuth  string
+		wantModel string
+	}{
+		{
+			name:      "settings model with API key",
+			apiKey:    "secret",
+			profile:   config.RewriteProfile{Name: "Email", SystemPrompt: "Write an email."},
+			wantAuth:  "Bearer secret",
+			wantModel: "default-model",
+		},
+		{
+			name:      "profile model without API key",
+			profile:   config.RewriteProfile{Name: "Notes", SystemPrompt: "Summarize.", Model: "profile-model"},
+			wantModel: "profile-model",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+					t.Errorf("request = %s %s, want POST /v1/chat/completions", r.Method, r.URL.Path)
+				}
+				if got := r.Header.Get("Content-Type"); got != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", got)
+				}
+				if got := r.Header.Get("Authorization"); got != tt.wantAuth {
+					t.Errorf("Authorization = %q, want %q", got, tt.wantAuth)
+				}
+
+				var req chatRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Errorf("failed to decode request: %v", err)
+					return
+				}
+				if req.Model != tt.wantModel {
+					t.Errorf("model = %q, want %q", req.Model, tt.wantModel)
+				}
+				want := []chatMessage{
+					{Role: "system", Content: tt.profile.SystemPrompt},
+					{Role: "user", Content: "the transcript"},
+				}
+				if len(req.Messages) != len(want) || req.Messages[0] != want[0] || req.Messages[1] != want[1] {
+					t.Errorf("messages = %+v, want %+v", req.Messages, want)
+				}
+
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"  rewritten\n"}}]}`))
+			}))
+			defer server.Close()
+
+			// A trailing slash on the base URL is ignored
+			c := NewClient(config.RewriteSettings{BaseURL: server.URL + "/v1/", Model: "default-model"}, tt.apiKey)
+			got, err := c.Rewrite(tt.profile, "the transcript")
+			if err != nil {
+				t.Fatalf("Rewrite() error = %v", err)
+			}
+			if got != "rewritten" {
+				t.Errorf("Rewrite() = %q, want %q", got, "rewritten")
+			}
+		})
+	}
+}
+
+func TestClientRewriteErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"error status includes the body", http.StatusUnauthorized, `{"error":"bad key"}`, `status 401: {"error":"bad key"}`},
+		{"server error", http.StatusInternalServerError, "boom", "status 500: boom"},
+		{"invalid JSON", http.StatusOK, "not json", "failed to decode response"},
+		{"no choices", http.StatusOK, `{"choices":[]}`, "no choices"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			c := NewClient(config.RewriteSettings{BaseURL: server.URL, Model: "m"}, "")
+			_, err := c.Rewrite(config.RewriteProfile{Name: "x"}, "text")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Rewrite() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("unreachable server", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		c := NewClient(config.RewriteSettings{BaseURL: server.URL}, "")
+		_, err := c.Rewrite(config.RewriteProfile{Name: "x"}, "text")
+		if err == nil || !strings.Contains(err.Error(), "failed to send request") {
+			t.Errorf("Rewrite() error = %v, want a send failure", err)
+		}
+	})
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	for profile, text := range map[string]string{"Email Draft": "Dear team", "notes": "- point"} {
+		if _, err := Save(dir, "id1", profile, text, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	artifacts, err := Load(dir, "id1", []config.RewriteProfile{{Name: "Email Draft"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 2 {
+		t.Fatalf("Load() returned %d artifacts, want 2", len(artifacts))
+	}
+	// Configured profiles keep their name, removed ones fall back to the slug
+	if artifacts[0].Profile != "Email Draft" || artifacts[0].Text != "Dear team" {
+		t.Errorf("artifacts[0] = %+v", artifacts[0])
+	}
+	if artifacts[1].Profile != "notes" || artifacts[1].Text != "- point" {
+		t.Errorf("artifacts[1] = %+v", artifacts[1])
+	}
+
+	if artifacts, err := Load(dir, "missing", nil, nil); err != nil || artifacts != nil {
+		t.Errorf("Load() for a missing id = %v, %v, want nothing", artifacts, err)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"lazywhisper/store"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var highlightStyle = lipgloss.NewStyle().Reverse(true)
+
+// wordPattern finds the words highlighted in search results
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+func newSearchInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = "/"
+	input.Placeholder = "search titles and text"
+	return input
+}
+
+// handleSearchUpdate edits the search query, filtering the list as you type.
+// Enter keeps the filter and returns to the list; Esc clears it.
+func (m model) handleSearchUpdate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "enter":
+		m.searching = false
+		m.searchInput.Blur()
+		return m, nil
+	case "esc":
+		m.searching = false
+		m.searchInput.Blur()
+		m.searchInput.SetValue("")
+		return m.applyFilters().selectTranscription(0), nil
+	case "up", "down":
+		// Arrow keys still move through the results while typing
+		if msg.String() == "up" && m.selectedIndex > 0 {
+			m = m.selectTranscription(m.selectedIndex - 1)
+		} else if msg.String() == "down" && m.selectedIndex < len(m.transcriptionFiles)-1 {
+			m = m.selectTranscription(m.selectedIndex + 1)
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	previous := m.searchInput.Value()
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	if m.searchInput.Value() != previous {
+		m = m.applyFilters().selectTranscription(0)
+	}
+	return m, cmd
+}
+
+// startSearch focuses the search input over the transcription list
+func (m model) startSearch() (model, tea.Cmd) {
+	m.searching = true
+	return m, m.searchInput.Focus()
+}
+
+// applyFilters narrows the list to the transcriptions matching the search
+// query, best match first, and the starred or tag filter
+func (m model) applyFilters() model {
+	entries := m.allTranscriptions
+	if m.searchActive() {
+		results, err := m.store.Search(m.searchInput.Value())
+		if err != nil {
+			m.err = err
+			return m
+		}
+		entries = make([]store.Entry, len(results))
+		for i, result := range results {
+			entries[i] = result.Entry
+		}
+	}
+
+	if m.listFilter != "" {
+		var filtered []store.Entry
+		for _, entry := range entries {
+			if matchesListFilter(entry, m.listFilter) {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+
+	m.transcriptionFiles = entries
+	return m
+}
+
+// searchActive reports whether the list is being filtered by a query
+func (m model) searchActive() bool {
+	return len(store.Tokenize(m.searchInput.Value())) > 0
+}
+
+// searchLine shows the query, list filter and title or tags prompt above the list while they are in use
+func (m model) searchLine() string {
+	var b strings.Builder
+	if m.listFilter != "" {
+		b.WriteString(helpStyle.Render("Showing " + listFilterLabel(m.listFilter)))
+		b.WriteString("\n")
+	}
+	if m.searching || m.searchActive() {
+		b.WriteString(m.searchInput.View())
+		b.WriteString("\n")
+	}
+	if m.editingField != "" {
+		b.WriteString(m.fieldInput.View())
+		b.WriteString("\n")
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return b.String() + "\n"
+}
+
+// highlightMatches marks the words in text matching the current search query
+func (m model) highlightMatches(text string) string {
+	if !m.searchActive() {
+		return text
+	}
+	terms := store.Tokenize(m.searchInput.Value())
+	return wordPattern.ReplaceAllStringFunc(text, func(word string) string {
+		normalized := store.Tokenize(word)[0]
+		for _, term := range terms {
+			if store.MatchTerm(normalized, term) > 0 {
+				return highlightStyle.Render(word)
+			}
+		}
+		return word
+	})
+}
+
+// searchHelp is shown in place of the list bindings while typing a query
+var searchHelp = []key.Binding{
+	key.NewBinding(key.WithKeys("enter"), key.WithHelp("<enter>", "Keep filter")),
+	key.NewBinding(key.WithKeys("esc"), key.WithHelp("<esc>", "Clear search")),
+	key.NewBinding(key.WithKeys("up", "down"), key.WithHelp("<↑/↓>", "Move through results")),
+}
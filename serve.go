@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"lazywhisper/audio"
+	"lazywhisper/config"
+	"lazywhisper/hooks"
+	"lazywhisper/server"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// apiTokenFile keeps the generated API token when none is set in the environment
+const apiTokenFile = "api_token"
+
+// runServe serves the HTTP API in the foreground until interrupted
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "", "address to listen on (defaults to serve.addr in config.json)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := checkDependencies(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *addr != "" {
+		settings.Serve.Addr = *addr
+	}
+
+	transcriptions, err := openStore(settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := purgeExpiredTrash(transcriptions, settings.Trash); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if _, _, err := applyRetention(transcriptions, settings.Retention); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
+	token, tokenSource, err := apiToken(settings.Serve, transcriptions.AppDataDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	hookRunner := hooks.New(settings.Hooks)
+	transcriber, saver, err := newTranscriber(os.Getenv("OPENAI_API_KEY"), settings, transcriptions, hookRunner)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	recorder := audio.NewRecorder()
+	var importDir string
+	// Keep plaintext recordings and uploads out of the app data directory; the
+	// store encrypts them into it once they are transcribed
+	if settings.Encryption.Enabled {
+		if importDir, err = config.TempDir(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		recorder.SetOutputDir(importDir)
+	}
+
+	api, err := server.New(server.Options{
+		Recorder:    recorder,
+		Transcriber: transcriber,
+		Store:       transcriptions,
+		Hooks:       hookRunner,
+		Token:       token,
+		Language:    settings.Transcription.Language,
+		ImportDir:   importDir,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	listener, err := net.Listen("tcp", settings.Serve.Addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if !isLoopback(settings.Serve.Addr) {
+		fmt.Fprintf(os.Stderr, "Warning: %s is reachable from other machines and the API has no TLS\n", settings.Serve.Addr)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpServer := &http.Server{Handler: api.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.Serve(listener)
+	}()
+
+	done := make(chan struct{})
+	defer close(done)
+	if settings.Sync.Enabled && settings.Sync.IntervalMinutes > 0 {
+		repo, err := openSync(settings.Sync, transcriptions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		interval := time.Duration(settings.Sync.IntervalMinutes) * time.Minute
+		go syncInBackground(repo, transcriptions, interval, done, func(line string) {
+			fmt.Println(line)
+		})
+	}
+
+	events, unsubscribe := api.Subscribe()
+	defer unsubscribe()
+
+	fmt.Printf("Serving the API on http://%s (Ctrl+C to stop)\n", listener.Addr())
+	fmt.Printf("Token: %s\n", tokenSource)
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Stopping...")
+			// End the event streams first; Shutdown waits for open requests
+			api.Close()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = httpServer.Shutdown(shutdownCtx)
+			// A second Ctrl+C quits without waiting
+			stop()
+			if saver.Pending() {
+				fmt.Println("Waiting for webhook deliveries (Ctrl+C to quit now)...")
+			}
+			saver.Wait()
+			hookRunner.Wait()
+			return 0
+		case err := <-serveErr:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		case e := <-events:
+			if line := formatServerEvent(e); line != "" {
+				fmt.Println(line)
+			}
+		case run := <-hookRunner.Runs():
+			fmt.Println(formatHookRun(run))
+		case err := <-saver.Warnings():
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// formatServerEvent renders an API event as a log line, or returns an empty
+// string for events not worth logging
+func formatServerEvent(e server.Event) string {
+	switch data := e.Data.(type) {
+	case server.Transcription:
+		return fmt.Sprintf("done       %s (%d words)", data.ID, data.WordCount)
+	case server.Failure:
+		return fmt.Sprintf("failed     %s: %s", data.ID, data.Error)
+	case server.Deleted:
+		return fmt.Sprintf("trashed    %s", data.ID)
+	}
+	return ""
+}
+
+// apiToken returns the token from the configured environment variable, or the
+// one generated on first use and kept in the app data directory, along with a
+// description of where it came from
+func apiToken(settings config.ServeSettings, appDataDir string) (string, string, error) {
+	if settings.TokenEnv != "" {
+		if token := strings.TrimSpace(os.Getenv(settings.TokenEnv)); token != "" {
+			return token, "from $" + settings.TokenEnv, nil
+		}
+	}
+
+	path := filepath.Join(appDataDir, apiTokenFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", "", fmt.Errorf("failed to read %s: %w", apiTokenFile, err)
+	}
+	if token := strings.TrimSpace(string(data)); token != "" {
+		return token, "in " + path, nil
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate a token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", "", fmt.Errorf("failed to save %s: %w", apiTokenFile, err)
+	}
+	return token, "generated in " + path, nil
+}
+
+// isLoopback reports whether addr only accepts connections from this machine
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Event types sent on the event stream
+const (
+	// StatusEvent carries the Status whenever recording starts or stops, or
+	// a transcription starts or finishes
+	StatusEvent = "status"
+	// CompletedEvent carries the saved Transcription
+	CompletedEvent = "transcription.completed"
+	// FailedEvent carries a Failure
+	FailedEvent = "transcription.failed"
+	// DeletedEvent carries a Deleted
+	DeletedEvent = "transcription.deleted"
+)
+
+// Event is a state change reported to subscribers
+type Event struct {
+	Type string
+	Data any
+}
+
+// Failure describes a transcription that couldn't be made
+type Failure struct {
+	ID    string `json:"id"`
+	Error string `json:"error"`
+}
+
+// Deleted names a transcription moved to the trash
+type Deleted struct {
+	ID string `json:"id"`
+}
+
+// broker fans events out to every subscriber. Events are dropped for a
+// subscriber that has fallen behind rather than hold up the others.
+type broker struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]bool
+	closed      bool
+}
+
+func newBroker() *broker {
+	return &broker{subscribers: map[chan Event]bool{}}
+}
+
+// subscribe returns a channel of events and a function to stop receiving
+// them. The channel is closed when the broker is.
+func (b *broker) subscribe() (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	events := make(chan Event, 32)
+	if b.closed {
+		close(events)
+		return events, func() {}
+	}
+	b.subscribers[events] = true
+	return events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.subscribers[events] {
+			delete(b.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+func (b *broker) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for events := range b.subscribers {
+		select {
+		case events <- e:
+		default:
+		}
+	}
+}
+
+// close ends every subscription
+func (b *broker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for events := range b.subscribers {
+		close(events)
+	}
+	b.subscribers = map[chan Event]bool{}
+	b.closed = true
+}
+
+// writeEvent writes e in the server-sent events format
+func writeEvent(w io.Writer, e Event) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	return err
+}
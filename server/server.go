@@ -0,0 +1,619 @@
+package server
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lazywhisper/audio"
+	"lazywhisper/config"
+	"lazywhisper/hooks"
+	"lazywhisper/store"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxUpload caps the size of an uploaded audio file
+const maxUpload = 1 << 30
+
+// keepAlive is how often an idle event stream gets a comment, so proxies and
+// clients don't time it out
+const keepAlive = 30 * time.Second
+
+//go:embed openapi.yaml
+var openAPI []byte
+
+// Options holds what the server records, transcribes and reads from
+type Options struct {
+	Recorder    *audio.Recorder
+	Transcriber *audio.Transcriber
+	Store       *store.Store
+	// Hooks runs the recording and error hooks; the completion hook runs in the transcriber
+	Hooks *hooks.Runner
+	// Token is the bearer token every API request must carry
+	Token string
+	// Language is used for transcriptions that don't ask for one. Empty lets the API detect it.
+	Language string
+	// ImportDir is where uploads are converted when they mustn't be written to
+	// the app data directory in plaintext. Empty converts into the recordings directory.
+	ImportDir string
+}
+
+// Status is what the server is doing
+type Status struct {
+	Recording        bool       `json:"recording"`
+	RecordingStarted *time.Time `json:"recording_started,omitempty"`
+	// Transcribing lists the ids of the transcriptions in progress
+	Transcribing []string `json:"transcribing"`
+}
+
+// Transcription is a saved transcription with its text
+type Transcription struct {
+	store.Entry
+	Text     string          `json:"text"`
+	Segments []audio.Segment `json:"segments,omitempty"`
+}
+
+type idBody struct {
+	ID string `json:"id"`
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// transcribeRequest holds the query parameters of the requests that start a transcription
+type transcribeRequest struct {
+	options audio.TranscribeOptions
+	// wait responds once the transcription is saved rather than straight away
+	wait bool
+}
+
+// Server exposes recording, transcription and the store over HTTP
+type Server struct {
+	opts   Options
+	events *broker
+
+	// recordMu serializes use of the recorder and reserving recording names
+	recordMu sync.Mutex
+
+	mu               sync.Mutex
+	recordingStarted time.Time
+	transcribing     map[string]bool
+
+	wg sync.WaitGroup
+}
+
+// New creates a server from opts
+func New(opts Options) (*Server, error) {
+	if opts.Token == "" {
+		return nil, fmt.Errorf("no API token configured")
+	}
+	return &Server{
+		opts:         opts,
+		events:       newBroker(),
+		transcribing: map[string]bool{},
+	}, nil
+}
+
+// Handler returns the API's routes. Everything but the OpenAPI description
+// needs the token.
+func (s *Server) Handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("/v1/status", s.handleStatus)
+	api.HandleFunc("/v1/recording/start", s.handleStart)
+	api.HandleFunc("/v1/recording/stop", s.handleStop)
+	api.HandleFunc("/v1/transcriptions", s.handleTranscriptions)
+	api.HandleFunc("/v1/transcriptions/", s.handleTranscription)
+	api.HandleFunc("/v1/search", s.handleSearch)
+	api.HandleFunc("/v1/events", s.handleEvents)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi.yaml", s.handleOpenAPI)
+	mux.Handle("/v1/", s.authenticate(api))
+	return mux
+}
+
+// Subscribe returns a channel of every event sent on the event stream and a
+// function to stop receiving them
+func (s *Server) Subscribe() (<-chan Event, func()) {
+	return s.events.subscribe()
+}
+
+// Status returns what the server is doing
+func (s *Server) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{Transcribing: []string{}}
+	if !s.recordingStarted.IsZero() {
+		started := s.recordingStarted
+		status.Recording = true
+		status.RecordingStarted = &started
+	}
+	for id := range s.transcribing {
+		status.Transcribing = append(status.Transcribing, id)
+	}
+	sort.Strings(status.Transcribing)
+	return status
+}
+
+// Close stops a recording in progress without transcribing it, waits for the
+// transcriptions in flight and ends every event stream
+func (s *Server) Close() {
+	s.recordMu.Lock()
+	if s.opts.Recorder.IsRecording() {
+		_ = s.opts.Recorder.StopRecording()
+	}
+	s.mu.Lock()
+	s.recordingStarted = time.Time{}
+	s.mu.Unlock()
+	s.recordMu.Unlock()
+
+	s.wg.Wait()
+	s.events.close()
+}
+
+// authenticate rejects requests without the bearer token. The token may also
+// be given as access_token in the query, since browsers can't set headers on
+// an EventSource.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("access_token")
+		if header := r.Header.Get("Authorization"); header != "" {
+			token, _ = strings.CutPrefix(header, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="lazywhisper"`)
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openAPI)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Status())
+}
+
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	s.recordMu.Lock()
+	defer s.recordMu.Unlock()
+	if s.Status().Recording {
+		writeError(w, http.StatusConflict, errors.New("recording is already in progress"))
+		return
+	}
+	if err := s.opts.Recorder.StartRecording(); err != nil {
+		s.opts.Hooks.Fire(hooks.Error, hooks.Env{Error: err.Error()})
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.mu.Lock()
+	s.recordingStarted = time.Now()
+	s.mu.Unlock()
+	s.opts.Hooks.Fire(hooks.RecordingStart, hooks.Env{AudioFile: s.opts.Recorder.GetOutputFile()})
+	writeJSON(w, http.StatusOK, s.publishStatus())
+}
+
+// handleStop stops the recording and transcribes it
+func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	req, err := s.parseTranscribeRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.recordMu.Lock()
+	s.mu.Lock()
+	started := s.recordingStarted
+	s.recordingStarted = time.Time{}
+	s.mu.Unlock()
+	if started.IsZero() {
+		s.recordMu.Unlock()
+		writeError(w, http.StatusConflict, errors.New("no recording in progress"))
+		return
+	}
+	// The recorder stops itself after 20 minutes; that recording is still transcribed
+	if s.opts.Recorder.IsRecording() {
+		err = s.opts.Recorder.StopRecording()
+	}
+	audioFile := s.opts.Recorder.GetOutputFile()
+	s.recordMu.Unlock()
+
+	if err != nil {
+		s.opts.Hooks.Fire(hooks.Error, hooks.Env{AudioFile: audioFile, Error: err.Error()})
+		s.publishStatus()
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.opts.Hooks.Fire(hooks.RecordingStop, hooks.Env{
+		AudioFile: audioFile,
+		Duration:  time.Since(started).Seconds(),
+	})
+	s.transcribe(w, audioFile, req, nil)
+}
+
+// handleTranscriptions lists the transcriptions, newest first, or transcribes an upload
+func (s *Server) handleTranscriptions(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodPost {
+		s.handleUpload(w, r)
+		return
+	}
+	entries, err := s.opts.Store.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// handleUpload converts the file in a multipart upload and transcribes it
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	req, err := s.parseTranscribeRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload)
+	upload, err := receiveUpload(r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("uploads are limited to %d bytes", maxUpload))
+			return
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer os.Remove(upload)
+
+	audioFile, discard, err := s.importUpload(upload)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.transcribe(w, audioFile, req, discard)
+}
+
+// handleTranscription returns or deletes a single transcription
+func (s *Server) handleTranscription(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/v1/transcriptions/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%w: %s", store.ErrNotFound, id))
+		return
+	}
+	if !allowMethod(w, r, http.MethodGet, http.MethodDelete) {
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := s.opts.Store.Trash(id); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		s.events.publish(Event{Type: DeletedEvent, Data: Deleted{ID: id}})
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	t, err := s.transcription(id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	query := r.URL.Query().Get("q")
+	if strings.TrimSpace(query) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("expected a search query in q"))
+		return
+	}
+	results, err := s.opts.Store.Search(query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if results == nil {
+		results = []store.Result{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleEvents streams events to the client until it disconnects or the server closes
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+	events, unsubscribe := s.events.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	// Start with the current status so clients don't need to ask for it
+	if err := writeEvent(w, Event{Type: StatusEvent, Data: s.Status()}); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	ping := time.NewTicker(keepAlive)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		case <-ping.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// transcribe transcribes audioFile in the background and responds with its
+// id, or with the saved transcription when the client waits for it. discard,
+// if set, removes the files once a transcription fails.
+func (s *Server) transcribe(w http.ResponseWriter, audioFile string, req transcribeRequest, discard func()) {
+	id := store.IDFromPath(audioFile)
+	s.mu.Lock()
+	s.transcribing[id] = true
+	s.mu.Unlock()
+	s.publishStatus()
+
+	// The transcription outlives the request so a client giving up on waiting doesn't lose it
+	done := make(chan error, 1)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		done <- s.runTranscription(id, audioFile, req.options, discard)
+	}()
+
+	location := "/v1/transcriptions/" + id
+	if !req.wait {
+		w.Header().Set("Location", location)
+		writeJSON(w, http.StatusAccepted, idBody{ID: id})
+		return
+	}
+	if err := <-done; err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	t, err := s.transcription(id)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.Header().Set("Location", location)
+	writeJSON(w, http.StatusCreated, t)
+}
+
+func (s *Server) runTranscription(id, audioFile string, opts audio.TranscribeOptions, discard func()) error {
+	_, err := s.opts.Transcriber.Transcribe(audioFile, opts)
+	s.mu.Lock()
+	delete(s.transcribing, id)
+	s.mu.Unlock()
+
+	if err != nil {
+		env := hooks.Env{ID: id, AudioFile: audioFile, Error: err.Error()}
+		if discard != nil {
+			discard()
+			env.AudioFile = ""
+		}
+		s.opts.Hooks.Fire(hooks.Error, env)
+		s.events.publish(Event{Type: FailedEvent, Data: Failure{ID: id, Error: err.Error()}})
+	} else if t, err := s.transcription(id); err == nil {
+		// Segments can be large; clients that want them can fetch the transcription
+		t.Segments = nil
+		s.events.publish(Event{Type: CompletedEvent, Data: t})
+	}
+	s.publishStatus()
+	return err
+}
+
+// transcription returns a saved transcription with its text and segments
+func (s *Server) transcription(id string) (Transcription, error) {
+	entry, err := s.opts.Store.Get(id)
+	if err != nil {
+		return Transcription{}, err
+	}
+	text, err := s.opts.Store.Text(id)
+	if err != nil {
+		return Transcription{}, err
+	}
+	t := Transcription{Entry: entry, Text: text}
+	// Transcriptions made before sidecars existed have no segments
+	details, err := s.opts.Store.Details(id)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Transcription{}, err
+	}
+	if err == nil {
+		t.Segments = details.Segments
+	}
+	return t, nil
+}
+
+// importUpload converts an upload into a wav file under a newly reserved
+// recording name, returning the file and a function that removes it again
+func (s *Server) importUpload(upload string) (string, func(), error) {
+	recordingsDir := filepath.Join(s.opts.Store.AppDataDir(), config.RecordingsDir)
+	s.recordMu.Lock()
+	_, audioFile := audio.RecordingPath(recordingsDir, time.Now())
+	// Reserve the name before releasing the lock so concurrent uploads don't collide
+	if err := os.WriteFile(audioFile, nil, 0644); err != nil {
+		s.recordMu.Unlock()
+		return "", nil, fmt.Errorf("failed to create %s: %w", audioFile, err)
+	}
+	s.recordMu.Unlock()
+
+	converted := audioFile
+	if s.opts.ImportDir != "" {
+		converted = filepath.Join(s.opts.ImportDir, filepath.Base(audioFile))
+	}
+	if err := audio.ConvertToWav(upload, converted); err != nil {
+		_ = os.Remove(audioFile)
+		return "", nil, err
+	}
+	return converted, func() {
+		_ = os.Remove(converted)
+		_ = os.Remove(audioFile)
+	}, nil
+}
+
+func (s *Server) publishStatus() Status {
+	status := s.Status()
+	s.events.publish(Event{Type: StatusEvent, Data: status})
+	return status
+}
+
+// parseTranscribeRequest reads the language, translate and wait query parameters
+func (s *Server) parseTranscribeRequest(r *http.Request) (transcribeRequest, error) {
+	query := r.URL.Query()
+	req := transcribeRequest{options: audio.TranscribeOptions{Language: s.opts.Language}}
+	if query.Has("language") {
+		req.options.Language = query.Get("language")
+	}
+	var err error
+	if value := query.Get("translate"); value != "" {
+		if req.options.Translate, err = strconv.ParseBool(value); err != nil {
+			return req, fmt.Errorf("translate must be true or false")
+		}
+	}
+	if value := query.Get("wait"); value != "" {
+		if req.wait, err = strconv.ParseBool(value); err != nil {
+			return req, fmt.Errorf("wait must be true or false")
+		}
+	}
+	return req, nil
+}
+
+// receiveUpload saves the file part of a multipart upload to a private
+// temporary file, keeping its extension as a hint for ffmpeg
+func receiveUpload(r *http.Request) (string, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return "", fmt.Errorf("expected a multipart/form-data upload: %w", err)
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return "", errors.New("the upload has no file part")
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read upload: %w", err)
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		dir, err := config.TempDir()
+		if err != nil {
+			return "", err
+		}
+		file, err := os.CreateTemp(dir, "upload-*"+uploadExt(part.FileName()))
+		if err != nil {
+			return "", fmt.Errorf("failed to save upload: %w", err)
+		}
+		_, err = io.Copy(file, part)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(file.Name())
+			return "", fmt.Errorf("failed to save upload: %w", err)
+		}
+		return file.Name(), nil
+	}
+}
+
+// uploadExt returns the extension of an uploaded file name, or an empty
+// string when it isn't a plain alphanumeric one
+func uploadExt(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if len(ext) < 2 || len(ext) > 6 {
+		return ""
+	}
+	for _, c := range ext[1:] {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return ""
+		}
+	}
+	return ext
+}
+
+// allowMethod responds with 405 unless the request uses one of methods
+func allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	return false
+}
+
+// writeStoreError responds with 404 for unknown transcriptions and 500 otherwise
+func writeStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorBody{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"lazywhisper/config"
+	"lazywhisper/store"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type storageLoadedMsg struct {
+	usage     []store.Usage
+	evictions []store.Eviction
+	err       error
+}
+
+type retentionAppliedMsg struct {
+	removed int
+	freed   int64
+	err     error
+}
+
+// storageKeyMap holds the bindings for the storage panel
+type storageKeyMap struct {
+	Open  key.Binding
+	Apply key.Binding
+}
+
+var storageKeys = storageKeyMap{
+	Open: key.NewBinding(
+		key.WithKeys("S"),
+		key.WithHelp("<S>", "Storage"),
+	),
+	Apply: key.NewBinding(
+		key.WithKeys("g"),
+		key.WithHelp("<g>", "Apply retention now"),
+	),
+}
+
+// retentionPolicy converts the retention settings into a store policy
+func retentionPolicy(settings config.RetentionSettings) store.RetentionPolicy {
+	return store.RetentionPolicy{
+		AudioMaxAge: time.Duration(settings.DeleteAudioAfterDays) * 24 * time.Hour,
+		MaxBytes:    int64(settings.MaxDiskGB * (1 << 30)),
+	}
+}
+
+// applyRetention deletes the recordings the retention settings no longer keep and
+// returns how many were removed and the bytes freed
+func applyRetention(s *store.Store, settings config.RetentionSettings) (int, int64, error) {
+	policy := retentionPolicy(settings)
+	if policy.AudioMaxAge == 0 && policy.MaxBytes == 0 {
+		return 0, 0, nil
+	}
+	evictions, err := s.PlanRetention(policy, time.Now())
+	if err != nil {
+		return 0, 0, err
+	}
+
+	removed, freed := 0, int64(0)
+	for _, eviction := range evictions {
+		if err := s.RemoveAudio(eviction.Entry.ID); err != nil {
+			return removed, freed, err
+		}
+		removed++
+		freed += eviction.Bytes
+	}
+	return removed, freed, nil
+}
+
+func loadStorage(s *store.Store, settings config.RetentionSettings) tea.Cmd {
+	return func() tea.Msg {
+		usage, err := s.Stats()
+		if err != nil {
+			return storageLoadedMsg{err: err}
+		}
+		evictions, err := s.PlanRetention(retentionPolicy(settings), time.Now())
+		return storageLoadedMsg{usage: usage, evictions: evictions, err: err}
+	}
+}
+
+func applyRetentionCmd(s *store.Store, settings config.RetentionSettings) tea.Cmd {
+	return func() tea.Msg {
+		removed, freed, err := applyRetention(s, settings)
+		return retentionAppliedMsg{removed: removed, freed: freed, err: err}
+	}
+}
+
+// handleStorageUpdate handles keys in the storage panel
+func (m model) handleStorageUpdate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, keys.Quit):
+		return m, tea.Quit
+
+	case key.Matches(msg, storageKeys.Apply):
+		if len(m.storageEvictions) > 0 {
+			m.player.Stop()
+			m.statusMessage = "Deleting recordings…"
+			return m, applyRetentionCmd(m.store, m.retention)
+		}
+
+	case key.Matches(msg, keys.Back), key.Matches(msg, storageKeys.Open):
+		m.showingStorage = false
+		m.statusMessage = ""
+	}
+	return m, nil
+}
+
+// storageView shows the disk usage of each category and what the retention policy would delete
+func (m model) storageView() string {
+	if m.storageUsage == nil {
+		return paddedStyle.Render("Measuring storage…")
+	}
+
+	var b strings.Builder
+	b.WriteString("Storage:\n\n")
+	var totalFiles int
+	var totalBytes int64
+	for _, usage := range m.storageUsage {
+		fmt.Fprintf(&b, "  %-16s %6d files  %10s\n", usage.Category, usage.Files, formatBytes(usage.Bytes))
+		totalFiles += usage.Files
+		totalBytes += usage.Bytes
+	}
+	fmt.Fprintf(&b, "  %-16s %6d files  %10s\n\n", "total", totalFiles, formatBytes(totalBytes))
+
+	b.WriteString(retentionSummary(m.retention))
+	if len(m.storageEvictions) > 0 {
+		var freed int64
+		for _, eviction := range m.storageEvictions {
+			freed += eviction.Bytes
+		}
+		fmt.Fprintf(&b, "\n%d recordings (%s) are due to be deleted. Press g to delete them now",
+			len(m.storageEvictions), formatBytes(freed))
+	}
+	if m.statusMessage != "" {
+		b.WriteString("\n\n" + m.statusMessage)
+	}
+	return paddedStyle.Render(b.String())
+}
+
+// retentionSummary describes the configured retention rules
+func retentionSummary(settings config.RetentionSettings) string {
+	var rules []string
+	if settings.DeleteAudioAfterDays > 0 {
+		rules = append(rules, fmt.Sprintf("recordings are deleted after %d days", settings.DeleteAudioAfterDays))
+	}
+	if settings.MaxDiskGB > 0 {
+		rules = append(rules, fmt.Sprintf("the oldest recordings are deleted above %g GB", settings.MaxDiskGB))
+	}
+	if len(rules) == 0 {
+		return helpStyle.Render("No retention rules are configured; recordings are kept forever")
+	}
+	return helpStyle.Render("Retention: " + strings.Join(rules, ", ") + ". Text is always kept")
+}
+
+// formatBytes renders a size with a binary unit, e.g. 1.5 MB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for q := n / unit; q >= unit; q /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+var storageHelp = []key.Binding{storageKeys.Apply, keys.Back}
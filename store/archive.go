@@ -0,0 +1,409 @@
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"lazywhisper/config"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archiveManifestName is the first file in an archive
+const archiveManifestName = "manifest.json"
+
+// archiveVersion is bumped when the archive layout changes
+const archiveVersion = 1
+
+// ArchiveManifest describes an archive: the index entries of the transcriptions
+// in it and a checksum for every file
+type ArchiveManifest struct {
+	Version int            `json:"version"`
+	Created time.Time      `json:"created"`
+	Entries []ArchiveEntry `json:"entries"`
+}
+
+// ArchiveEntry is a transcription in an archive
+type ArchiveEntry struct {
+	Entry Entry `json:"entry"`
+	// Hash is the SHA-256 of the transcription text, used to tell conflicting
+	// transcriptions with the same id from copies of the same one
+	Hash  string        `json:"hash"`
+	Files []ArchiveFile `json:"files"`
+}
+
+// ArchiveFile is a file in an archive, relative to the app data directory
+type ArchiveFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ImportResult reports what an import did with each transcription in the archive
+type ImportResult struct {
+	Imported []string
+	// Identical transcriptions were already in the store with the same text
+	Identical []string
+	// Conflicts have the same id as a transcription in the store or its trash
+	// but different text. The local copy is kept.
+	Conflicts []string
+}
+
+// ExportArchive writes every transcription with its recording, sidecar,
+// rewrites and revisions to w as a gzipped tar. Files are written decrypted so
+// the archive can be imported with a different key.
+func (s *Store) ExportArchive(w io.Writer) (*ArchiveManifest, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &ArchiveManifest{Version: archiveVersion, Created: time.Now()}
+	for _, entry := range entries {
+		archived, err := s.archiveEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Entries = append(manifest.Entries, archived)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := writeTarFile(tw, archiveManifestName, data); err != nil {
+		return nil, err
+	}
+	for _, archived := range manifest.Entries {
+		for _, file := range archived.Files {
+			data, err := s.cipher.ReadFile(filepath.Join(s.appDataDir, filepath.FromSlash(file.Path)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", file.Path, err)
+			}
+			if err := writeTarFile(tw, file.Path, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// archiveEntry lists and checksums the files belonging to an entry
+func (s *Store) archiveEntry(entry Entry) (ArchiveEntry, error) {
+	text, err := s.Text(entry.ID)
+	if err != nil {
+		return ArchiveEntry{}, err
+	}
+	archived := ArchiveEntry{Entry: entry, Hash: checksum([]byte(text))}
+
+	for _, rel := range s.ownedPaths(entry) {
+		err := filepath.WalkDir(filepath.Join(s.appDataDir, rel), func(p string, d fs.DirEntry, err error) error {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			if err != nil || d.IsDir() {
+				return err
+			}
+			relPath, err := filepath.Rel(s.appDataDir, p)
+			if err != nil {
+				return err
+			}
+			// Recordings kept elsewhere in the app data directory couldn't be imported
+			if !archivePathAllowed(filepath.ToSlash(relPath), entry.ID) {
+				return nil
+			}
+			data, err := s.cipher.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			archived.Files = append(archived.Files, ArchiveFile{
+				Path:   filepath.ToSlash(relPath),
+				Size:   int64(len(data)),
+				SHA256: checksum(data),
+			})
+			return nil
+		})
+		if err != nil {
+			return ArchiveEntry{}, fmt.Errorf("failed to read %s: %w", rel, err)
+		}
+	}
+	return archived, nil
+}
+
+// ImportArchive merges an archive written by ExportArchive into the store.
+// Every file is checked against the manifest before anything is imported.
+// Transcriptions already in the store are never overwritten.
+func (s *Store) ImportArchive(r io.Reader) (*ImportResult, error) {
+	// Unpack outside the app data directory so plaintext never lands in it
+	staging, err := os.MkdirTemp("", "lazywhisper-import-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := unpackArchive(r, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{}
+	var importErr error
+	for _, archived := range manifest.Entries {
+		id := archived.Entry.ID
+		if _, ok := s.entries[id]; ok {
+			local, err := s.Text(id)
+			if err != nil {
+				importErr = err
+				break
+			}
+			if checksum([]byte(local)) == archived.Hash {
+				result.Identical = append(result.Identical, id)
+			} else {
+				result.Conflicts = append(result.Conflicts, id)
+			}
+			continue
+		}
+		// Don't bring back a transcription that was deleted here
+		if _, err := s.trashedEntry(id); err == nil {
+			result.Conflicts = append(result.Conflicts, id)
+			continue
+		}
+
+		if err := s.importEntry(staging, archived); err != nil {
+			importErr = err
+			break
+		}
+		result.Imported = append(result.Imported, id)
+	}
+
+	// Persist what was imported before a failure too, or its files would be
+	// left in the app data directory without an index entry
+	if len(result.Imported) == 0 {
+		return result, importErr
+	}
+	if err := s.write(); err != nil {
+		return result, err
+	}
+	if err := s.writeSearch(); err != nil {
+		return result, err
+	}
+	return result, importErr
+}
+
+// importEntry copies an archived transcription's files out of staging and
+// adds it to the index. If any file fails, the files it already copied are
+// removed again.
+func (s *Store) importEntry(staging string, archived ArchiveEntry) (err error) {
+	var created []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(created) - 1; i >= 0; i-- {
+			os.Remove(created[i])
+		}
+	}()
+
+	for _, file := range archived.Files {
+		data, err := os.ReadFile(filepath.Join(staging, filepath.FromSlash(file.Path)))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		target := filepath.Join(s.appDataDir, filepath.FromSlash(file.Path))
+		created = append(created, missingDirs(filepath.Dir(target))...)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+		}
+		_, statErr := os.Lstat(target)
+		if err := s.writeFileAtomic(target, data); err != nil {
+			return fmt.Errorf("failed to import %s: %w", file.Path, err)
+		}
+		// Only clean up files this import created
+		if errors.Is(statErr, os.ErrNotExist) {
+			created = append(created, target)
+		}
+	}
+
+	id := archived.Entry.ID
+	text, err := s.Text(id)
+	if err != nil {
+		return err
+	}
+	entry := archived.Entry
+	// The recording isn't in the archive when it lived outside the app data directory
+	if entry.AudioPath != "" && !archiveHas(archived, entry.AudioPath) {
+		entry.AudioPath = ""
+	}
+	s.entries[id] = &entry
+	s.indexText(id, entry.searchTitle(), text)
+	return nil
+}
+
+// missingDirs returns dir and those of its parents that don't exist yet,
+// outermost first
+func missingDirs(dir string) []string {
+	var missing []string
+	for {
+		if _, err := os.Lstat(dir); !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		missing = append([]string{dir}, missing...)
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return missing
+}
+
+// unpackArchive extracts an archive into dir, verifying every file against
+// the manifest, and returns the manifest
+func unpackArchive(r io.Reader, dir string) (*ArchiveManifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	header, err := tr.Next()
+	if err != nil || header.Name != archiveManifestName {
+		return nil, fmt.Errorf("not a lazywhisper archive: %s must come first", archiveManifestName)
+	}
+	var manifest ArchiveManifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if manifest.Version > archiveVersion {
+		return nil, fmt.Errorf("archive version %d is newer than this lazywhisper supports", manifest.Version)
+	}
+
+	expected := map[string]ArchiveFile{}
+	for _, archived := range manifest.Entries {
+		if id := archived.Entry.ID; id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+			return nil, fmt.Errorf("archive contains an invalid id %q", id)
+		}
+		for _, file := range archived.Files {
+			if !archivePathAllowed(file.Path, archived.Entry.ID) {
+				return nil, fmt.Errorf("archive contains an unexpected path %s", file.Path)
+			}
+			expected[file.Path] = file
+		}
+	}
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		file, ok := expected[header.Name]
+		if !ok || header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("archive contains %s, which isn't in the manifest", header.Name)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", header.Name, err)
+		}
+		hash := sha256.New()
+		size, err := io.Copy(io.MultiWriter(out, hash), tr)
+		out.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s: %w", header.Name, err)
+		}
+		if size != file.Size || hex.EncodeToString(hash.Sum(nil)) != file.SHA256 {
+			return nil, fmt.Errorf("checksum mismatch for %s; the archive is damaged", header.Name)
+		}
+		delete(expected, header.Name)
+	}
+
+	for missing := range expected {
+		return nil, fmt.Errorf("archive is missing %s", missing)
+	}
+	return &manifest, nil
+}
+
+// archivePathAllowed reports whether an archived path is one of the files an
+// entry may own, so a crafted archive can't write anywhere else
+func archivePathAllowed(p, id string) bool {
+	if p != path.Clean(p) || path.IsAbs(p) || strings.HasPrefix(p, "../") {
+		return false
+	}
+	dir, name := path.Split(p)
+	switch strings.TrimSuffix(dir, "/") {
+	case config.TranscriptionsDir, config.RecordingsDir:
+		return strings.TrimSuffix(name, path.Ext(name)) == id
+	}
+	for _, owned := range []string{config.DerivedDir, config.RevisionsDir} {
+		if strings.HasPrefix(p, owned+"/"+id+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func archiveHas(archived ArchiveEntry, rel string) bool {
+	for _, file := range archived.Files {
+		if file.Path == filepath.ToSlash(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"bytes"
+	"lazywhisper/audio"
+	"lazywhisper/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportArchiveKeepsNoOrphansOnFailure(t *testing.T) {
+	source, err := Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"2024-01-01-10-00-00", "2024-02-01-10-00-00"} {
+		recording := filepath.Join(source.appDataDir, config.RecordingsDir, id+".wav")
+		if err := os.WriteFile(recording, []byte("wav"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := source.Save(recording, &audio.TranscriptionResponse{Text: "text of " + id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var archive bytes.Buffer
+	if _, err := source.ExportArchive(&archive); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	s, err := Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The older transcription is imported second; its sidecar can't be written
+	failing := "2024-01-01-10-00-00"
+	obstacle := filepath.Join(dir, config.TranscriptionsDir, failing+".json")
+	if err := os.MkdirAll(filepath.Join(obstacle, "in-the-way"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.ImportArchive(&archive)
+	if err == nil {
+		t.Fatal("ImportArchive() succeeded, want the sidecar error")
+	}
+	if len(result.Imported) != 1 || result.Imported[0] != "2024-02-01-10-00-00" {
+		t.Fatalf("imported %v, want only the newer transcription", result.Imported)
+	}
+	for _, path := range []string{s.TextPath(failing), filepath.Join(dir, config.RecordingsDir, failing+".wav")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind by the failed import", path)
+		}
+	}
+
+	// What was imported before the failure is in the index on disk
+	reopened, err := Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := reopened.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != "2024-02-01-10-00-00" {
+		t.Errorf("index after reopening = %+v, want the imported transcription", entries)
+	}
+	if text, err := reopened.Text("2024-02-01-10-00-00"); err != nil || text != "text of 2024-02-01-10-00-00" {
+		t.Errorf("Text() = %q, %v", text, err)
+	}
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"lazywhisper/config"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EncryptExisting encrypts every plaintext file the store owns in place and
+// returns how many files were encrypted. Files that are already encrypted are
+// skipped, so an interrupted run can be repeated.
+func (s *Store) EncryptExisting() (int, error) {
+	if s.cipher == nil {
+		return 0, fmt.Errorf("encryption is not enabled")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths := []string{s.indexPath(), s.searchIndexPath()}
+	for _, dir := range []string{
+		config.TranscriptionsDir,
+		config.RecordingsDir,
+		config.DerivedDir,
+		config.RevisionsDir,
+		config.TrashDir,
+	} {
+		err := filepath.WalkDir(filepath.Join(s.appDataDir, dir), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Leave the sync repository and its .gitignore alone
+			if hidden(d) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			// Skip files still being written
+			if d.IsDir() || strings.HasSuffix(path, ".tmp") || strings.HasSuffix(path, ".part") {
+				return nil
+			}
+			paths = append(paths, path)
+			return nil
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return 0, fmt.Errorf("failed to list %s: %w", dir, err)
+		}
+	}
+
+	encrypted := 0
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			continue // Missing, or a placeholder for an import in progress
+		}
+		changed, err := s.cipher.EncryptFile(path)
+		if err != nil {
+			return encrypted, fmt.Errorf("failed to encrypt %s: %w", path, err)
+		}
+		if changed {
+			encrypted++
+		}
+	}
+
+	// The index was rewritten; don't mistake that for another process changing it
+	return encrypted, s.load()
+}
+
+// adoptAudio returns the path to index for the recording of a saved
+// transcription. With encryption on, recordings in the app data or temp
+// directory are encrypted into the recordings directory and the plaintext is
+// removed. Recordings elsewhere belong to the user and are left alone.
+func (s *Store) adoptAudio(id, audioFile string) (string, error) {
+	if s.cipher == nil || !(within(s.appDataDir, audioFile) || within(os.TempDir(), audioFile)) {
+		return s.relativeAudioPath(audioFile), nil
+	}
+	if _, err := os.Stat(audioFile); errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+
+	data, err := s.cipher.ReadFile(audioFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read recording: %w", err)
+	}
+	target := filepath.Join(s.appDataDir, config.RecordingsDir, id+filepath.Ext(audioFile))
+	if err := s.writeFileAtomic(target, data); err != nil {
+		return "", fmt.Errorf("failed to encrypt recording: %w", err)
+	}
+	if filepath.Clean(audioFile) != target {
+		if err := os.Remove(audioFile); err != nil {
+			return "", fmt.Errorf("failed to remove plaintext recording: %w", err)
+		}
+	}
+	return s.relativeAudioPath(target), nil
+}
+
+// within reports whether path is inside dir
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"lazywhisper/audio"
+	"lazywhisper/config"
+	"lazywhisper/crypt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptExistingSkipsTheSyncRepository(t *testing.T) {
+	dir := t.TempDir()
+	plain, err := Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Save(filepath.Join(dir, config.RecordingsDir, "2024-01-01-10-00-00.wav"), &audio.TranscriptionResponse{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	transcriptions := filepath.Join(dir, config.TranscriptionsDir)
+	gitFiles := map[string]string{
+		filepath.Join(transcriptions, ".git", "HEAD"):                  "ref: refs/heads/main\n",
+		filepath.Join(transcriptions, ".git", "objects", "ab", "cdef"): "object",
+		filepath.Join(transcriptions, ".gitignore"):                    "*.tmp\n",
+	}
+	for path, content := range gitFiles {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cipher, err := crypt.Unlock(dir, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(dir, cipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EncryptExisting(); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range gitFiles {
+		if got, _ := os.ReadFile(path); string(got) != want {
+			t.Errorf("%s = %q, want it left alone", path, got)
+		}
+	}
+	text, err := os.ReadFile(s.TextPath("2024-01-01-10-00-00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !crypt.Encrypted(text) {
+		t.Error("the transcription wasn't encrypted")
+	}
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTitleLength is the longest suggested title, cut at a word boundary
+const maxTitleLength = 60
+
+// SuggestTitle proposes a title from the first sentence of a transcription
+func SuggestTitle(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if i := strings.IndexAny(text, ".!?"); i >= 0 {
+		text = text[:i]
+	}
+	if utf8.RuneCountInString(text) <= maxTitleLength {
+		return text
+	}
+
+	title := ""
+	for _, word := range strings.Fields(text) {
+		if utf8.RuneCountInString(title)+1+utf8.RuneCountInString(word) > maxTitleLength {
+			break
+		}
+		title = strings.TrimSpace(title + " " + word)
+	}
+	if title == "" {
+		title = string([]rune(text)[:maxTitleLength])
+	}
+	return title + "…"
+}
+
+// ParseTags splits a comma or space separated list of tags, dropping a leading
+// "#", blanks and duplicates
+func ParseTags(input string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		tag = strings.ToLower(strings.TrimLeft(tag, "#"))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// HasTag reports whether the entry is tagged with tag
+func (e Entry) HasTag(tag string) bool {
+	for _, t := range e.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"lazywhisper/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Usage is the number of files and bytes in one storage category
+type Usage struct {
+	Category string `json:"category"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// RetentionPolicy decides which recordings are deleted to save space.
+// Transcription text is always kept.
+type RetentionPolicy struct {
+	// AudioMaxAge deletes recordings older than this. Zero keeps them.
+	AudioMaxAge time.Duration
+	// MaxBytes caps the size of the app data directory, deleting the oldest
+	// recordings first. Every category reported by Stats except the trash and
+	// the sync history counts toward the cap, since deleting recordings can't
+	// shrink those. Zero means no cap.
+	MaxBytes int64
+}
+
+// Eviction is a recording the retention policy would delete
+type Eviction struct {
+	Entry  Entry
+	Bytes  int64
+	Reason string
+}
+
+// SyncCategory is the git history kept in the transcriptions directory when
+// sync is on, reported by Stats apart from the transcriptions
+const SyncCategory = "sync"
+
+// storageCategories are the app data subdirectories reported by Stats, in display order
+var storageCategories = []string{
+	config.RecordingsDir,
+	config.TranscriptionsDir,
+	config.DerivedDir,
+	config.RevisionsDir,
+	config.SubtitlesDir,
+	config.TrashDir,
+}
+
+// Stats counts the files and bytes in each storage category. Files directly in
+// the app data directory, like the index and settings, are reported as "other".
+// Hidden files, like the sync repository, aren't counted in their directory;
+// the sync history is reported as SyncCategory when it exists.
+func (s *Store) Stats() ([]Usage, error) {
+	usage := make([]Usage, 0, len(storageCategories)+2)
+	for _, category := range storageCategories {
+		files, bytes, err := dirUsage(filepath.Join(s.appDataDir, category))
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, Usage{Category: category, Files: files, Bytes: bytes})
+	}
+
+	files, bytes, err := dirUsage(filepath.Join(s.appDataDir, config.TranscriptionsDir, ".git"))
+	if err != nil {
+		return nil, err
+	}
+	if files > 0 {
+		usage = append(usage, Usage{Category: SyncCategory, Files: files, Bytes: bytes})
+	}
+
+	other := Usage{Category: "other"}
+	entries, err := os.ReadDir(s.appDataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.appDataDir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed while we were looking
+		}
+		other.Files++
+		other.Bytes += info.Size()
+	}
+	return append(usage, other), nil
+}
+
+// PlanRetention lists the recordings policy would delete, oldest first.
+// Recordings outside the app data directory are never deleted.
+func (s *Store) PlanRetention(policy RetentionPolicy, now time.Time) ([]Eviction, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// Oldest first, so the size cap evicts the oldest recordings
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.Before(entries[j].Created)
+	})
+
+	var total int64
+	if policy.MaxBytes > 0 {
+		stats, err := s.Stats()
+		if err != nil {
+			return nil, err
+		}
+		for _, usage := range stats {
+			// Evicting recordings can't shrink the trash or the sync history
+			if usage.Category != config.TrashDir && usage.Category != SyncCategory {
+				total += usage.Bytes
+			}
+		}
+	}
+
+	var evictions []Eviction
+	for _, entry := range entries {
+		if entry.AudioPath == "" || filepath.IsAbs(entry.AudioPath) {
+			continue
+		}
+		info, err := os.Stat(s.AudioFile(entry))
+		if err != nil {
+			continue // Already gone; the index is fixed when the entry is next saved
+		}
+
+		var reason string
+		switch {
+		case policy.AudioMaxAge > 0 && now.Sub(entry.Created) > policy.AudioMaxAge:
+			reason = fmt.Sprintf("older than %d days", int(policy.AudioMaxAge.Hours()/24))
+		case policy.MaxBytes > 0 && total > policy.MaxBytes:
+			reason = "over the disk usage cap"
+		default:
+			continue
+		}
+		evictions = append(evictions, Eviction{Entry: entry, Bytes: info.Size(), Reason: reason})
+		total -= info.Size()
+	}
+	return evictions, nil
+}
+
+// RemoveAudio deletes the recording of a transcription, keeping its text
+func (s *Store) RemoveAudio(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if entry.AudioPath == "" {
+		return nil
+	}
+	if filepath.IsAbs(entry.AudioPath) {
+		return fmt.Errorf("recording %s is outside the app data directory", entry.AudioPath)
+	}
+
+	if err := os.Remove(filepath.Join(s.appDataDir, entry.AudioPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete recording: %w", err)
+	}
+	entry.AudioPath = ""
+	return s.write()
+}
+
+// dirUsage counts the files and bytes under dir, leaving out hidden files and
+// directories inside it. A missing directory is empty.
+func dirUsage(dir string) (int, int64, error) {
+	var files int
+	var bytes int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if path != dir && hidden(d) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Removed while we were walking
+		}
+		files++
+		bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to measure %s: %w", dir, err)
+	}
+	return files, bytes, nil
+}
+
+// hidden reports whether an entry is a dotfile or directory, like the sync
+// repository's .git and .gitignore, which the store doesn't own
+func hidden(d fs.DirEntry) bool {
+	return strings.HasPrefix(d.Name(), ".")
+}
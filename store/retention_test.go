@@ -0,0 +1,118 @@
+package store
+
+import (
+	"lazywhisper/audio"
+	"lazywhisper/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlanRetentionIgnoresTrashForTheCap(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"2024-01-01-10-00-00", "2024-02-01-10-00-00"} {
+		recording := filepath.Join(dir, config.RecordingsDir, id+".wav")
+		if err := os.WriteFile(recording, make([]byte, 1000), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Save(recording, &audio.TranscriptionResponse{Text: "hello"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A trash much larger than the cap
+	if err := os.WriteFile(filepath.Join(dir, config.TrashDir, "old.txt"), make([]byte, 100000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept int64
+	for _, usage := range stats {
+		if usage.Category != config.TrashDir {
+			kept += usage.Bytes
+		}
+	}
+
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	evictions, err := s.PlanRetention(RetentionPolicy{MaxBytes: kept}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evictions) != 0 {
+		t.Errorf("PlanRetention() at the cap evicted %d recordings, want none", len(evictions))
+	}
+
+	evictions, err = s.PlanRetention(RetentionPolicy{MaxBytes: kept - 1}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evictions) != 1 || evictions[0].Entry.ID != "2024-01-01-10-00-00" {
+		t.Errorf("PlanRetention() just over the cap = %+v, want only the oldest recording", evictions)
+	}
+}
+
+func TestStatsReportsSyncHistorySeparately(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcriptions := filepath.Join(dir, config.TranscriptionsDir)
+	if err := os.WriteFile(filepath.Join(transcriptions, "a.txt"), make([]byte, 10), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(transcriptions, ".git", "objects"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(transcriptions, ".git", "objects", "pack"), make([]byte, 5000), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := map[string]Usage{}
+	for _, u := range stats {
+		usage[u.Category] = u
+	}
+	if got := usage[config.TranscriptionsDir]; got.Files != 1 || got.Bytes != 10 {
+		t.Errorf("transcriptions = %+v, want only a.txt", got)
+	}
+	if got := usage[SyncCategory]; got.Files != 1 || got.Bytes != 5000 {
+		t.Errorf("sync = %+v, want the git history", got)
+	}
+
+	// The history doesn't count toward the cap
+	recording := filepath.Join(dir, config.RecordingsDir, "2024-01-01-10-00-00.wav")
+	if err := os.WriteFile(recording, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(recording, &audio.TranscriptionResponse{Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = s.Stats(); err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, u := range stats {
+		if u.Category != SyncCategory && u.Category != config.TrashDir {
+			total += u.Bytes
+		}
+	}
+	evictions, err := s.PlanRetention(RetentionPolicy{MaxBytes: total}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evictions) != 0 {
+		t.Errorf("PlanRetention() evicted %d recordings because of the sync history", len(evictions))
+	}
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"lazywhisper/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// revisionLayout names revision files so they sort oldest first
+const revisionLayout = "20060102T150405.000000000"
+
+// ErrNoRevisions is returned when there is nothing to undo
+var ErrNoRevisions = errors.New("no earlier revisions")
+
+// Revision is an earlier version of a transcription's text
+type Revision struct {
+	Saved time.Time
+	Path  string
+}
+
+// SaveText replaces the text of a transcription, keeping the previous text as
+// a revision so the edit can be undone
+func (s *Store) SaveText(id, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return err
+	}
+	if _, ok := s.entries[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	previous, err := s.Text(id)
+	if err != nil {
+		return err
+	}
+	if previous == text {
+		return nil
+	}
+	if err := s.saveRevision(id, previous); err != nil {
+		return err
+	}
+	return s.replaceText(id, text)
+}
+
+// Undo restores the most recent revision of a transcription and returns its
+// text. The text it replaces becomes the most recent revision, so undoing again
+// brings it back.
+func (s *Store) Undo(id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return "", err
+	}
+	if _, ok := s.entries[id]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	revisions, err := s.revisions(id)
+	if err != nil {
+		return "", err
+	}
+	if len(revisions) == 0 {
+		return "", ErrNoRevisions
+	}
+	latest := revisions[len(revisions)-1]
+
+	content, err := s.cipher.ReadFile(latest.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read revision: %w", err)
+	}
+	// Keep the text being replaced so the undo can be undone in turn
+	current, err := s.Text(id)
+	if err != nil {
+		return "", err
+	}
+	if err := s.saveRevision(id, current); err != nil {
+		return "", err
+	}
+	if err := s.replaceText(id, string(content)); err != nil {
+		return "", err
+	}
+	if err := os.Remove(latest.Path); err != nil {
+		return "", fmt.Errorf("failed to remove restored revision: %w", err)
+	}
+	return string(content), nil
+}
+
+// revisions returns the earlier versions of a transcription, oldest first
+func (s *Store) revisions(id string) ([]Revision, error) {
+	files, err := os.ReadDir(s.revisionsDir(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revisions: %w", err)
+	}
+
+	var revisions []Revision
+	for _, file := range files {
+		name := strings.TrimSuffix(file.Name(), ".txt")
+		saved, err := time.ParseInLocation(revisionLayout, name, time.Local)
+		if file.IsDir() || err != nil {
+			continue
+		}
+		revisions = append(revisions, Revision{Saved: saved, Path: filepath.Join(s.revisionsDir(id), file.Name())})
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Saved.Before(revisions[j].Saved)
+	})
+	return revisions, nil
+}
+
+func (s *Store) saveRevision(id, text string) error {
+	dir := s.revisionsDir(id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, time.Now().Format(revisionLayout)+".txt")
+	if err := s.writeFileAtomic(path, []byte(text)); err != nil {
+		return fmt.Errorf("failed to save revision: %w", err)
+	}
+	return nil
+}
+
+// replaceText writes new text for an existing entry and updates its word count and search terms
+func (s *Store) replaceText(id, text string) error {
+	if err := s.writeFileAtomic(s.TextPath(id), []byte(text)); err != nil {
+		return fmt.Errorf("failed to save transcription: %w", err)
+	}
+
+	s.entries[id].WordCount = len(strings.Fields(text))
+	if err := s.write(); err != nil {
+		return err
+	}
+	s.indexText(id, s.entries[id].searchTitle(), text)
+	return s.writeSearch()
+}
+
+func (s *Store) revisionsDir(id string) string {
+	return filepath.Join(s.appDataDir, config.RevisionsDir, id)
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"errors"
+	"lazywhisper/audio"
+	"testing"
+)
+
+func TestUndoCanBeUndone(t *testing.T) {
+	s, err := Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := "2024-01-01-10-00-00"
+	if _, err := s.Undo(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Undo() of a missing transcription = %v, want ErrNotFound", err)
+	}
+	if err := s.Save(id+".wav", &audio.TranscriptionResponse{Text: "original"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Undo(id); !errors.Is(err, ErrNoRevisions) {
+		t.Errorf("Undo() before any edit = %v, want ErrNoRevisions", err)
+	}
+	if err := s.SaveText(id, "edited"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"original", "edited", "original"} {
+		got, err := s.Undo(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text, _ := s.Text(id); got != want || text != want {
+			t.Errorf("Undo() = %q with %q saved, want %q", got, text, want)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const SearchIndexFile = "search_index.json"
+
+// Match scores for a query term against an indexed term
+const (
+	fuzzyMatch  = 1
+	prefixMatch = 2
+	exactMatch  = 3
+	// titleBoost is added per query term found in the title or tags
+	titleBoost = 2
+)
+
+// Result is a transcription matching a search query
+type Result struct {
+	Entry   Entry  `json:"entry"`
+	Score   int    `json:"score"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// searchIndex maps terms to the transcriptions containing them. Each
+// transcription's terms are kept too so it can be replaced without rescanning
+// everything.
+type searchIndex struct {
+	Terms map[string][]string `json:"terms"`
+	Docs  map[string][]string `json:"docs"`
+
+	loadedAt time.Time
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{Terms: map[string][]string{}, Docs: map[string][]string{}}
+}
+
+// Search returns the transcriptions matching every term in query, best match
+// first. Terms match words that equal them, start with them, or are a typo away.
+func (s *Store) Search(query string) ([]Result, error) {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	var scores map[string]int
+	for _, term := range terms {
+		termScores := map[string]int{}
+		for indexed, ids := range s.search.Terms {
+			score := MatchTerm(indexed, term)
+			if score == 0 {
+				continue
+			}
+			for _, id := range ids {
+				termScores[id] = max(termScores[id], score)
+			}
+		}
+
+		// Every term has to match somewhere
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for id, score := range scores {
+			if termScores[id] == 0 {
+				delete(scores, id)
+			} else {
+				scores[id] = score + termScores[id]
+			}
+		}
+	}
+
+	var results []Result
+	for id, score := range scores {
+		entry, ok := s.entries[id]
+		if !ok {
+			continue
+		}
+		for _, term := range terms {
+			if matchesAny(Tokenize(entry.searchTitle()), term) {
+				score += titleBoost
+			}
+		}
+		results = append(results, Result{Entry: *entry, Score: score})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Entry.Created.After(results[j].Entry.Created)
+	})
+
+	for i := range results {
+		if text, err := s.Text(results[i].Entry.ID); err == nil {
+			results[i].Snippet = snippet(text, terms)
+		}
+	}
+	return results, nil
+}
+
+// Tokenize splits text into lowercase words for indexing and matching
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// MatchTerm scores how well a word matches a query term, or returns 0 if it doesn't
+func MatchTerm(word, term string) int {
+	switch {
+	case word == term:
+		return exactMatch
+	case strings.HasPrefix(word, term):
+		return prefixMatch
+	}
+
+	// Allow more typos in longer terms; short terms would match almost anything
+	allowed := 0
+	switch n := len([]rune(term)); {
+	case n >= 8:
+		allowed = 2
+	case n >= 4:
+		allowed = 1
+	}
+	if allowed > 0 && withinDistance(word, term, allowed) {
+		return fuzzyMatch
+	}
+	return 0
+}
+
+func matchesAny(words []string, term string) bool {
+	for _, word := range words {
+		if MatchTerm(word, term) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// withinDistance reports whether the edit distance between a and b is at most limit
+func withinDistance(a, b string, limit int) bool {
+	ra, rb := []rune(a), []rune(b)
+	if abs(len(ra)-len(rb)) > limit {
+		return false
+	}
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		best := current[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			best = min(best, current[j])
+		}
+		// Stop early once every path is over the limit
+		if best > limit {
+			return false
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)] <= limit
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// snippet returns the line of text with the first match, trimmed to a readable length
+func snippet(text string, terms []string) string {
+	const width = 80
+
+	for _, line := range strings.Split(text, "\n") {
+		words := strings.Fields(line)
+		for i, word := range words {
+			if !matchesAny(Tokenize(word), terms[0]) {
+				continue
+			}
+			start := max(i-5, 0)
+			result := strings.Join(words[start:], " ")
+			if len([]rune(result)) > width {
+				result = string([]rune(result)[:width]) + "…"
+			}
+			if start > 0 {
+				result = "…" + result
+			}
+			return result
+		}
+	}
+	return ""
+}
+
+func (s *Store) searchIndexPath() string {
+	return filepath.Join(s.appDataDir, SearchIndexFile)
+}
+
+// searchTitle is the metadata indexed alongside the text: the title and tags
+func (e *Entry) searchTitle() string {
+	if e == nil {
+		return ""
+	}
+	return e.Title + " " + strings.Join(e.Tags, " ")
+}
+
+// indexText replaces the terms indexed for id with those in its title and text
+func (s *Store) indexText(id, title, text string) {
+	s.unindexText(id)
+	s.search.Docs[id] = []string{}
+
+	seen := map[string]bool{}
+	for _, term := range append(Tokenize(title), Tokenize(text)...) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		s.search.Docs[id] = append(s.search.Docs[id], term)
+		s.search.Terms[term] = append(s.search.Terms[term], id)
+	}
+}
+
+func (s *Store) unindexText(id string) {
+	for _, term := range s.search.Docs[id] {
+		ids := s.search.Terms[term]
+		for i, existing := range ids {
+			if existing == id {
+				ids = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(s.search.Terms, term)
+		} else {
+			s.search.Terms[term] = ids
+		}
+	}
+	delete(s.search.Docs, id)
+}
+
+// reindex updates the search terms for a single entry after its text or title changed
+func (s *Store) reindex(id string) error {
+	text, err := s.Text(id)
+	if err != nil {
+		return err
+	}
+	s.indexText(id, s.entries[id].searchTitle(), text)
+	return s.writeSearch()
+}
+
+// loadSearch reads the search index, rebuilding it if it is missing or out of
+// step with the metadata index
+func (s *Store) loadSearch() error {
+	data, err := s.cipher.ReadFile(s.searchIndexPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return s.rebuildSearch()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read search index: %w", err)
+	}
+
+	index := newSearchIndex()
+	if err := json.Unmarshal(data, index); err != nil || index.Terms == nil || index.Docs == nil {
+		return s.rebuildSearch()
+	}
+	if len(index.Docs) != len(s.entries) {
+		return s.rebuildSearch()
+	}
+	for id := range s.entries {
+		if _, ok := index.Docs[id]; !ok {
+			return s.rebuildSearch()
+		}
+	}
+
+	if info, err := os.Stat(s.searchIndexPath()); err == nil {
+		index.loadedAt = info.ModTime()
+	}
+	s.search = index
+	return nil
+}
+
+// refreshSearch reloads the search index if another process has written it since we read it
+func (s *Store) refreshSearch() error {
+	info, err := os.Stat(s.searchIndexPath())
+	if err != nil || info.ModTime().After(s.search.loadedAt) {
+		return s.loadSearch()
+	}
+	return nil
+}
+
+func (s *Store) rebuildSearch() error {
+	s.search = newSearchIndex()
+	for id, entry := range s.entries {
+		text, err := s.Text(id)
+		if err != nil {
+			text = "" // Indexed by title alone until the next rebuild
+		}
+		s.indexText(id, entry.searchTitle(), text)
+	}
+	return s.writeSearch()
+}
+
+func (s *Store) writeSearch() error {
+	data, err := json.Marshal(s.search)
+	if err != nil {
+		return fmt.Errorf("failed to encode search index: %w", err)
+	}
+	if err := s.writeFileAtomic(s.searchIndexPath(), data); err != nil {
+		return fmt.Errorf("failed to write search index: %w", err)
+	}
+	if info, err := os.Stat(s.searchIndexPath()); err == nil {
+		s.search.loadedAt = info.ModTime()
+	}
+	return nil
+}
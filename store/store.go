@@ -0,0 +1,491 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lazywhisper/audio"
+	"lazywhisper/config"
+	"lazywhisper/crypt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	IndexFile = "index.json"
+	idLayout  = "2006-01-02-15-04-05"
+)
+
+// ErrNotFound is returned for ids that aren't in the index
+var ErrNotFound = errors.New("transcription not found")
+
+// Entry is the indexed metadata for a single transcription
+type Entry struct {
+	ID        string    `json:"id"`
+	Created   time.Time `json:"created"`
+	Duration  float64   `json:"duration,omitempty"`
+	Language  string    `json:"language,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	WordCount int       `json:"word_count"`
+	Title     string    `json:"title,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Starred   bool      `json:"starred,omitempty"`
+	// AudioPath is relative to the app data directory. Empty once the recording is gone.
+	AudioPath   string `json:"audio_path,omitempty"`
+	Translation bool   `json:"translation,omitempty"`
+	Redactions  int    `json:"redactions,omitempty"`
+}
+
+// Store owns the transcriptions directory and the index describing it. The
+// index is reloaded whenever another process (e.g. `lazywhisper watch`) has
+// written it since we last read it.
+type Store struct {
+	appDataDir string
+	// cipher encrypts everything the store writes; nil keeps files in plaintext
+	cipher *crypt.Cipher
+
+	mu       sync.Mutex
+	entries  map[string]*Entry
+	loadedAt time.Time
+	search   *searchIndex
+}
+
+// Open loads the index from the app data directory, rebuilding it from the
+// transcription files when it doesn't exist. Missing subdirectories are
+// created. Files are encrypted with cipher when it is not nil.
+func Open(appDataDir string, cipher *crypt.Cipher) (*Store, error) {
+	if err := config.CreateDirs(appDataDir); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		appDataDir: appDataDir,
+		cipher:     cipher,
+		entries:    map[string]*Entry{},
+		search:     newSearchIndex(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(s.indexPath()); errors.Is(err, os.ErrNotExist) {
+		if err := s.rebuild(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.loadSearch(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenDefault opens the store in the standard app data directory
+func OpenDefault(cipher *crypt.Cipher) (*Store, error) {
+	appDataDir, err := config.GetAppDataDir()
+	if err != nil {
+		return nil, err
+	}
+	return Open(appDataDir, cipher)
+}
+
+// AppDataDir returns the directory the store lives in
+func (s *Store) AppDataDir() string {
+	return s.appDataDir
+}
+
+// Cipher returns the cipher files are encrypted with, or nil when encryption is off
+func (s *Store) Cipher() *crypt.Cipher {
+	return s.cipher
+}
+
+// Rebuild discards the index and recreates it from the files on disk
+func (s *Store) Rebuild() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rebuild()
+}
+
+// List returns every transcription, newest first
+func (s *Store) List() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Created.Equal(entries[j].Created) {
+			return entries[i].Created.After(entries[j].Created)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+	return entries, nil
+}
+
+// Get returns the entry for id
+func (s *Store) Get(id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return Entry{}, err
+	}
+
+	e, ok := s.entries[id]
+	if !ok {
+		return Entry{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return *e, nil
+}
+
+// Text returns the saved text of a transcription
+func (s *Store) Text(id string) (string, error) {
+	content, err := s.cipher.ReadFile(s.TextPath(id))
+	if err != nil {
+		return "", fmt.Errorf("failed to read transcription: %w", err)
+	}
+	return string(content), nil
+}
+
+// Details returns the full API response saved in the sidecar. Transcriptions
+// made before sidecars existed return an error wrapping os.ErrNotExist.
+func (s *Store) Details(id string) (*audio.TranscriptionResponse, error) {
+	data, err := s.cipher.ReadFile(s.SidecarPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcription details: %w", err)
+	}
+	var result audio.TranscriptionResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse transcription details: %w", err)
+	}
+	return &result, nil
+}
+
+// Save writes the text and sidecar for a recording and adds it to the index
+func (s *Store) Save(audioFile string, result *audio.TranscriptionResponse) error {
+	id := IDFromPath(audioFile)
+
+	if err := s.cipher.WriteFile(s.TextPath(id), []byte(result.Text), 0644); err != nil {
+		return fmt.Errorf("failed to save transcription: %w", err)
+	}
+	if err := s.writeSidecar(id, result); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	// Keep user metadata when a recording is transcribed again
+	entry, ok := s.entries[id]
+	if !ok {
+		entry = &Entry{ID: id, Created: createdFromID(id, time.Now()), Model: audio.Model}
+		s.entries[id] = entry
+	}
+	audioPath, err := s.adoptAudio(id, audioFile)
+	if err != nil {
+		return err
+	}
+	entry.AudioPath = audioPath
+	applyDetails(entry, result)
+
+	if err := s.write(); err != nil {
+		return err
+	}
+	s.indexText(id, entry.searchTitle(), result.Text)
+	return s.writeSearch()
+}
+
+// Update changes the metadata of an existing entry
+func (s *Store) Update(id string, change func(*Entry)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	change(entry)
+	entry.ID = id
+	if err := s.write(); err != nil {
+		return err
+	}
+	// The title is searchable too
+	return s.reindex(id)
+}
+
+// Delete removes a transcription, its sidecar, audio, derived files and revisions
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	if err := os.Remove(s.TextPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete transcription: %w", err)
+	}
+	_ = os.Remove(s.SidecarPath(id)) // Older transcriptions have none
+	if entry.AudioPath != "" {
+		_ = os.Remove(s.AudioFile(*entry)) // The audio may already be gone
+	}
+	_ = os.RemoveAll(filepath.Join(s.appDataDir, config.DerivedDir, id))
+	_ = os.RemoveAll(s.revisionsDir(id))
+
+	delete(s.entries, id)
+	if err := s.write(); err != nil {
+		return err
+	}
+	s.unindexText(id)
+	return s.writeSearch()
+}
+
+// TextPath returns the path of the .txt for id
+func (s *Store) TextPath(id string) string {
+	return filepath.Join(s.appDataDir, config.TranscriptionsDir, id+".txt")
+}
+
+// SidecarPath returns the path of the .json sidecar for id
+func (s *Store) SidecarPath(id string) string {
+	return filepath.Join(s.appDataDir, config.TranscriptionsDir, id+".json")
+}
+
+// AudioFile returns the absolute path of an entry's recording
+func (s *Store) AudioFile(e Entry) string {
+	if e.AudioPath == "" || filepath.IsAbs(e.AudioPath) {
+		return e.AudioPath
+	}
+	return filepath.Join(s.appDataDir, e.AudioPath)
+}
+
+// IDFromPath returns the id for a recording or transcription file
+func IDFromPath(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+func (s *Store) indexPath() string {
+	return filepath.Join(s.appDataDir, IndexFile)
+}
+
+func (s *Store) relativeAudioPath(audioFile string) string {
+	if _, err := os.Stat(audioFile); err != nil {
+		return ""
+	}
+	if rel, err := filepath.Rel(s.appDataDir, audioFile); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return audioFile
+}
+
+func (s *Store) writeSidecar(id string, result *audio.TranscriptionResponse) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode transcription details: %w", err)
+	}
+	if err := s.cipher.WriteFile(s.SidecarPath(id), data, 0644); err != nil {
+		return fmt.Errorf("failed to save transcription details: %w", err)
+	}
+	return nil
+}
+
+// refresh reloads the index if another process has written it since we read it
+func (s *Store) refresh() error {
+	info, err := os.Stat(s.indexPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return s.rebuild()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	if info.ModTime().After(s.loadedAt) {
+		if err := s.load(); err != nil {
+			return err
+		}
+	}
+	return s.refreshSearch()
+}
+
+func (s *Store) load() error {
+	info, err := os.Stat(s.indexPath())
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+	data, err := s.cipher.ReadFile(s.indexPath())
+	if err != nil {
+		return fmt.Errorf("failed to read index: %w", err)
+	}
+
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse index: %w", err)
+	}
+
+	s.entries = map[string]*Entry{}
+	for _, e := range entries {
+		s.entries[e.ID] = e
+	}
+	s.loadedAt = info.ModTime()
+	return nil
+}
+
+// write saves the index
+func (s *Store) write() error {
+	entries := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+
+	if err := s.writeFileAtomic(s.indexPath(), data); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+
+	if info, err := os.Stat(s.indexPath()); err == nil {
+		s.loadedAt = info.ModTime()
+	}
+	return nil
+}
+
+// rebuild scans the transcriptions directory and recreates the index
+func (s *Store) rebuild() error {
+	dir := filepath.Join(s.appDataDir, config.TranscriptionsDir)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read transcriptions directory: %w", err)
+	}
+
+	s.entries = map[string]*Entry{}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".txt" {
+			continue
+		}
+		id := IDFromPath(file.Name())
+
+		modTime := time.Now()
+		if info, err := file.Info(); err == nil {
+			modTime = info.ModTime()
+		}
+		s.entries[id] = s.entryFromFiles(id, modTime)
+	}
+
+	if err := s.write(); err != nil {
+		return err
+	}
+	return s.rebuildSearch()
+}
+
+// entryFromFiles recreates the index entry for a transcription from its text and sidecar
+func (s *Store) entryFromFiles(id string, modTime time.Time) *Entry {
+	entry := &Entry{ID: id, Created: createdFromID(id, modTime), Model: audio.Model}
+
+	if text, err := s.Text(id); err == nil {
+		entry.WordCount = len(strings.Fields(text))
+	}
+	// Older transcriptions have no sidecar, so details are optional
+	if details, err := s.Details(id); err == nil {
+		applyDetails(entry, details)
+	}
+	entry.AudioPath = s.relativeAudioPath(filepath.Join(s.appDataDir, config.RecordingsDir, id+".wav"))
+	return entry
+}
+
+// Reload picks up transcription files changed outside the store, e.g. by a
+// sync. Entries the index already has keep their title, tags and star;
+// entries whose text is gone are dropped from the index.
+func (s *Store) Reload(ids ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		info, err := os.Stat(s.TextPath(id))
+		if errors.Is(err, os.ErrNotExist) {
+			delete(s.entries, id)
+			s.unindexText(id)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read transcription: %w", err)
+		}
+
+		entry, ok := s.entries[id]
+		if !ok {
+			entry = s.entryFromFiles(id, info.ModTime())
+			s.entries[id] = entry
+		}
+		text, err := s.Text(id)
+		if err != nil {
+			return err
+		}
+		entry.WordCount = len(strings.Fields(text))
+		s.indexText(id, entry.searchTitle(), text)
+	}
+
+	if err := s.write(); err != nil {
+		return err
+	}
+	return s.writeSearch()
+}
+
+func applyDetails(entry *Entry, result *audio.TranscriptionResponse) {
+	if result.Model != "" {
+		entry.Model = result.Model
+	}
+	entry.Duration = result.Duration
+	entry.Language = result.Language
+	entry.WordCount = len(strings.Fields(result.Text))
+	entry.Translation = result.Translation
+	entry.Redactions = result.Redactions
+}
+
+// createdFromID parses the recording timestamp ids are named after, falling back when it isn't one
+func createdFromID(id string, fallback time.Time) time.Time {
+	if t, err := time.ParseInLocation(idLayout, id, time.Local); err == nil {
+		return t
+	}
+	return fallback
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path so
+// readers never see a partial file
+func (s *Store) writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := s.cipher.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
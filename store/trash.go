@@ -0,0 +1,209 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lazywhisper/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// trashEntryFile holds the index entry of a trashed transcription inside its trash directory
+const trashEntryFile = "entry.json"
+
+// TrashedEntry is a transcription in the trash
+type TrashedEntry struct {
+	Entry
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// Trash moves a transcription and everything made from it into the trash,
+// where it can be restored until it is purged
+func (s *Store) Trash(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	dir := s.trashDir(id)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clear trash for %s: %w", id, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	data, err := json.MarshalIndent(TrashedEntry{Entry: *entry, DeletedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode trashed entry: %w", err)
+	}
+	if err := s.cipher.WriteFile(filepath.Join(dir, trashEntryFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to save trashed entry: %w", err)
+	}
+
+	for _, rel := range s.ownedPaths(*entry) {
+		if err := moveIfExists(filepath.Join(s.appDataDir, rel), filepath.Join(dir, rel)); err != nil {
+			return fmt.Errorf("failed to move %s to trash: %w", rel, err)
+		}
+	}
+
+	delete(s.entries, id)
+	if err := s.write(); err != nil {
+		return err
+	}
+	s.unindexText(id)
+	return s.writeSearch()
+}
+
+// Restore moves a trashed transcription back into the store
+func (s *Store) Restore(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return err
+	}
+
+	trashed, err := s.trashedEntry(id)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.entries[id]; ok {
+		return fmt.Errorf("transcription %s already exists", id)
+	}
+
+	dir := s.trashDir(id)
+	for _, rel := range s.ownedPaths(trashed.Entry) {
+		if err := moveIfExists(filepath.Join(dir, rel), filepath.Join(s.appDataDir, rel)); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", rel, err)
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clear trash for %s: %w", id, err)
+	}
+
+	entry := trashed.Entry
+	s.entries[id] = &entry
+	if err := s.write(); err != nil {
+		return err
+	}
+	return s.reindex(id)
+}
+
+// Trashed returns the transcriptions in the trash, most recently deleted first
+func (s *Store) Trashed() ([]TrashedEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dirs, err := os.ReadDir(filepath.Join(s.appDataDir, config.TrashDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	var trashed []TrashedEntry
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		entry, err := s.trashedEntry(dir.Name())
+		if err != nil {
+			continue // Half written by an interrupted delete; purged with the rest eventually
+		}
+		trashed = append(trashed, entry)
+	}
+	sort.Slice(trashed, func(i, j int) bool {
+		return trashed[i].DeletedAt.After(trashed[j].DeletedAt)
+	})
+	return trashed, nil
+}
+
+// Purge permanently removes a transcription from the trash
+func (s *Store) Purge(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.trashDir(id)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("%w in trash: %s", ErrNotFound, id)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to purge %s: %w", id, err)
+	}
+	return nil
+}
+
+// PurgeTrash permanently removes everything deleted longer than maxAge ago and
+// returns how many transcriptions were purged
+func (s *Store) PurgeTrash(maxAge time.Duration) (int, error) {
+	trashed, err := s.Trashed()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, entry := range trashed {
+		if time.Since(entry.DeletedAt) < maxAge {
+			continue
+		}
+		if err := s.Purge(entry.ID); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+func (s *Store) trashDir(id string) string {
+	return filepath.Join(s.appDataDir, config.TrashDir, id)
+}
+
+func (s *Store) trashedEntry(id string) (TrashedEntry, error) {
+	data, err := s.cipher.ReadFile(filepath.Join(s.trashDir(id), trashEntryFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return TrashedEntry{}, fmt.Errorf("%w in trash: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return TrashedEntry{}, fmt.Errorf("failed to read trashed entry: %w", err)
+	}
+
+	var entry TrashedEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return TrashedEntry{}, fmt.Errorf("failed to parse trashed entry: %w", err)
+	}
+	return entry, nil
+}
+
+// ownedPaths lists the files and directories belonging to an entry, relative
+// to the app data directory. Audio kept outside the app data directory isn't ours
+// to move.
+func (s *Store) ownedPaths(entry Entry) []string {
+	paths := []string{
+		filepath.Join(config.TranscriptionsDir, entry.ID+".txt"),
+		filepath.Join(config.TranscriptionsDir, entry.ID+".json"),
+		filepath.Join(config.DerivedDir, entry.ID),
+		filepath.Join(config.RevisionsDir, entry.ID),
+	}
+	if entry.AudioPath != "" && !filepath.IsAbs(entry.AudioPath) {
+		paths = append(paths, entry.AudioPath)
+	}
+	return paths
+}
+
+// moveIfExists renames from to to, creating the parent directory. Missing sources are skipped.
+func moveIfExists(from, to string) error {
+	if _, err := os.Stat(from); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
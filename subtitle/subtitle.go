@@ -0,0 +1,261 @@
+package subtitle
+
+import (
+	"fmt"
+	"io"
+	"lazywhisper/audio"
+	"sort"
+	"strings"
+)
+
+type Format string
+
+const (
+	SRT Format = "srt"
+	VTT Format = "vtt"
+)
+
+// ParseFormat validates a format name given on the command line
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case SRT:
+		return SRT, nil
+	case VTT:
+		return VTT, nil
+	default:
+		return "", fmt.Errorf("unknown subtitle format %q (expected srt or vtt)", name)
+	}
+}
+
+// Options controls how segments are broken into cues
+type Options struct {
+	// MaxLineLength is the maximum number of characters on a line
+	MaxLineLength int
+	// MaxLines is the maximum number of lines in a single cue
+	MaxLines int
+	// MaxCueDuration is the longest a single cue stays on screen, in seconds
+	MaxCueDuration float64
+}
+
+// DefaultOptions follows common broadcast captioning guidelines
+func DefaultOptions() Options {
+	return Options{
+		MaxLineLength:  42,
+		MaxLines:       2,
+		MaxCueDuration: 7,
+	}
+}
+
+// minCueDuration is how long a cue with no duration of its own is shown, in seconds
+const minCueDuration = 0.5
+
+// Cue is a single caption shown between Start and End seconds
+type Cue struct {
+	Start float64
+	End   float64
+	Lines []string
+}
+
+// token is a word from a segment's text with its estimated timing
+type token struct {
+	text  string
+	start float64
+	end   float64
+}
+
+// Build turns transcription segments into caption cues. Word timings are used
+// to split long segments when they line up with the segment text, otherwise
+// timings are interpolated by character position.
+func Build(result *audio.TranscriptionResponse, opts Options) []Cue {
+	segments := append([]audio.Segment(nil), result.Segments...)
+	sort.SliceStable(segments, func(i, j int) bool {
+		return segments[i].Start < segments[j].Start
+	})
+
+	var cues []Cue
+	for _, segment := range segments {
+		tokens := tokenize(segment, wordsWithin(result.Words, segment))
+		cues = append(cues, group(tokens, opts)...)
+	}
+
+	// Whisper segments occasionally overlap slightly; never show two cues at once
+	for i := 0; i+1 < len(cues); i++ {
+		if cues[i].End > cues[i+1].Start {
+			cues[i].End = cues[i+1].Start
+		}
+	}
+
+	return mergeEmpty(cues, opts)
+}
+
+// mergeEmpty folds cues left with no duration into their neighbours so their
+// text is never lost: into the previous cue, or the next one when there is no
+// previous. A lone empty cue is shown for minCueDuration instead.
+func mergeEmpty(cues []Cue, opts Options) []Cue {
+	var merged []Cue
+	var carried *Cue
+	for _, cue := range cues {
+		if cue.End > cue.Start {
+			if carried != nil {
+				cue.Start = min(cue.Start, carried.Start)
+				cue.Lines = wrap(append(words(carried.Lines), words(cue.Lines)...), opts.MaxLineLength)
+				carried = nil
+			}
+			merged = append(merged, cue)
+			continue
+		}
+		if len(merged) > 0 {
+			last := &merged[len(merged)-1]
+			last.Lines = wrap(append(words(last.Lines), words(cue.Lines)...), opts.MaxLineLength)
+			continue
+		}
+		if carried == nil {
+			carried = &Cue{Start: cue.Start, End: cue.End}
+		}
+		carried.Lines = append(carried.Lines, cue.Lines...)
+	}
+	if carried != nil {
+		carried.End = max(carried.End, carried.Start+minCueDuration)
+		merged = append(merged, *carried)
+	}
+	return merged
+}
+
+// words splits cue lines back into words for rewrapping
+func words(lines []string) []string {
+	return strings.Fields(strings.Join(lines, " "))
+}
+
+// wordsWithin returns the words whose midpoint falls inside the segment
+func wordsWithin(words []audio.Word, segment audio.Segment) []audio.Word {
+	var within []audio.Word
+	for _, word := range words {
+		mid := (word.Start + word.End) / 2
+		if mid >= segment.Start && mid < segment.End {
+			within = append(within, word)
+		}
+	}
+	return within
+}
+
+func tokenize(segment audio.Segment, words []audio.Word) []token {
+	fields := strings.Fields(segment.Text)
+	tokens := make([]token, len(fields))
+
+	// The words API strips punctuation, so only trust it when it lines up one to one
+	if len(words) == len(fields) {
+		for i, field := range fields {
+			tokens[i] = token{text: field, start: words[i].Start, end: words[i].End}
+		}
+		return tokens
+	}
+
+	total := 0
+	for _, field := range fields {
+		total += len(field) + 1
+	}
+	span := segment.End - segment.Start
+	position := 0
+	for i, field := range fields {
+		start := segment.Start + span*float64(position)/float64(total)
+		position += len(field) + 1
+		end := segment.Start + span*float64(position)/float64(total)
+		tokens[i] = token{text: field, start: start, end: end}
+	}
+	return tokens
+}
+
+// group packs tokens into cues that respect the line and duration limits
+func group(tokens []token, opts Options) []Cue {
+	var cues []Cue
+	var current []token
+
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		cues = append(cues, Cue{
+			Start: current[0].start,
+			End:   current[len(current)-1].end,
+			Lines: wrap(texts(current), opts.MaxLineLength),
+		})
+		current = nil
+	}
+
+	for _, t := range tokens {
+		candidate := append(append([]token(nil), current...), t)
+		tooLong := len(wrap(texts(candidate), opts.MaxLineLength)) > opts.MaxLines
+		tooSlow := t.end-candidate[0].start > opts.MaxCueDuration
+		if len(current) > 0 && (tooLong || tooSlow) {
+			flush()
+		}
+		current = append(current, t)
+	}
+	flush()
+
+	return cues
+}
+
+func texts(tokens []token) []string {
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		words[i] = t.text
+	}
+	return words
+}
+
+// wrap greedily fills lines up to maxLength characters. A single word longer
+// than the limit gets a line to itself rather than being broken.
+func wrap(words []string, maxLength int) []string {
+	var lines []string
+	var line string
+	for _, word := range words {
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) <= maxLength:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Write renders cues in the given format
+func Write(w io.Writer, format Format, cues []Cue) error {
+	var b strings.Builder
+	if format == VTT {
+		b.WriteString("WEBVTT\n\n")
+	}
+	for i, cue := range cues {
+		if format == SRT {
+			fmt.Fprintf(&b, "%d\n", i+1)
+		}
+		fmt.Fprintf(&b, "%s --> %s\n", timestamp(cue.Start, format), timestamp(cue.End, format))
+		b.WriteString(strings.Join(cue.Lines, "\n"))
+		b.WriteString("\n\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// timestamp formats seconds as HH:MM:SS,mmm for SRT or HH:MM:SS.mmm for WebVTT
+func timestamp(seconds float64, format Format) string {
+	millis := int64(seconds*1000 + 0.5)
+	separator := ","
+	if format == VTT {
+		separator = "."
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d",
+		millis/3600000,
+		millis/60000%60,
+		millis/1000%60,
+		separator,
+		millis%1000,
+	)
+}
@@ -0,0 +1,166 @@
+package subtitle
+
+import (
+	"lazywhisper/audio"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func cueText(cues []Cue) string {
+	var all []string
+	for _, cue := range cues {
+		all = append(all, strings.Join(cue.Lines, " "))
+	}
+	return strings.Join(all, " | ")
+}
+
+func TestBuildKeepsTextOfCuesClampedToNothing(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []audio.Segment
+		want     string
+	}{
+		{
+			name: "overlapped cue merges into the previous one",
+			segments: []audio.Segment{
+				{Start: 0, End: 2, Text: "first part"},
+				{Start: 1, End: 1, Text: "lost"},
+				{Start: 1, End: 3, Text: "second part"},
+			},
+			want: "first part lost | second part",
+		},
+		{
+			name: "empty first cue carries into the next one",
+			segments: []audio.Segment{
+				{Start: 0, End: 0, Text: "hello"},
+				{Start: 0, End: 2, Text: "world"},
+			},
+			want: "hello world",
+		},
+		{
+			name: "lone empty cue gets a minimum duration",
+			segments: []audio.Segment{
+				{Start: 4, End: 4, Text: "only"},
+			},
+			want: "only",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cues := Build(&audio.TranscriptionResponse{Segments: tt.segments}, DefaultOptions())
+			if got := cueText(cues); got != tt.want {
+				t.Errorf("text = %q, want %q", got, tt.want)
+			}
+			for i, cue := range cues {
+				if cue.End <= cue.Start {
+					t.Errorf("cue %d has no duration: %v-%v", i, cue.Start, cue.End)
+				}
+				if i > 0 && cue.Start < cues[i-1].End {
+					t.Errorf("cue %d overlaps the previous one", i)
+				}
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  []string
+	}{
+		{"fits on one line", "hello world", 11, []string{"hello world"}},
+		{"breaks at the width", "hello world", 10, []string{"hello", "world"}},
+		{"fills each line greedily", "a bb ccc dd e", 6, []string{"a bb", "ccc dd", "e"}},
+		{"long word gets its own line", "a incomprehensibilities b", 8, []string{"a", "incomprehensibilities", "b"}},
+		{"nothing to wrap", "", 10, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wrap(strings.Fields(tt.text), tt.width)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("wrap(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSplitsCuesLongerThanMaxCueDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		segment     audio.Segment
+		maxDuration float64
+		wantCues    int
+	}{
+		{"short segment stays whole", audio.Segment{Start: 0, End: 5, Text: "one two three four five"}, 7, 1},
+		{"twice the limit", audio.Segment{Start: 0, End: 12, Text: "a b c d e f g h i j k l"}, 7, 2},
+		{"many times the limit", audio.Segment{Start: 10, End: 40, Text: "a b c d e f g h i j k l m n o p q r s t u v w x y z a b c d"}, 7, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.MaxCueDuration = tt.maxDuration
+			cues := Build(&audio.TranscriptionResponse{Segments: []audio.Segment{tt.segment}}, opts)
+			if len(cues) != tt.wantCues {
+				t.Fatalf("got %d cues, want %d: %+v", len(cues), tt.wantCues, cues)
+			}
+			if got := cueText(cues); strings.ReplaceAll(got, " | ", " ") != tt.segment.Text {
+				t.Errorf("text = %q, want every word of %q in order", got, tt.segment.Text)
+			}
+			for i, cue := range cues {
+				if cue.End-cue.Start > tt.maxDuration {
+					t.Errorf("cue %d lasts %.2fs, longer than %.0fs", i, cue.End-cue.Start, tt.maxDuration)
+				}
+			}
+			if cues[0].Start != tt.segment.Start || cues[len(cues)-1].End != tt.segment.End {
+				t.Errorf("cues span %v-%v, want the segment's %v-%v", cues[0].Start, cues[len(cues)-1].End, tt.segment.Start, tt.segment.End)
+			}
+		})
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		srt     string
+		vtt     string
+	}{
+		{0, "00:00:00,000", "00:00:00.000"},
+		{1.5, "00:00:01,500", "00:00:01.500"},
+		{59.9996, "00:01:00,000", "00:01:00.000"},
+		{3599.999, "00:59:59,999", "00:59:59.999"},
+		{3600, "01:00:00,000", "01:00:00.000"},
+		{3661.042, "01:01:01,042", "01:01:01.042"},
+		{36000.25, "10:00:00,250", "10:00:00.250"},
+	}
+	for _, tt := range tests {
+		if got := timestamp(tt.seconds, SRT); got != tt.srt {
+			t.Errorf("timestamp(%v, SRT) = %q, want %q", tt.seconds, got, tt.srt)
+		}
+		if got := timestamp(tt.seconds, VTT); got != tt.vtt {
+			t.Errorf("timestamp(%v, VTT) = %q, want %q", tt.seconds, got, tt.vtt)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cues := []Cue{{Start: 3599.5, End: 3601.25, Lines: []string{"first line", "second line"}}}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{SRT, "1\n00:59:59,500 --> 01:00:01,250\nfirst line\nsecond line\n\n"},
+		{VTT, "WEBVTT\n\n00:59:59.500 --> 01:00:01.250\nfirst line\nsecond line\n\n"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := Write(&b, tt.format, cues); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("Write(%s) = %q, want %q", tt.format, b.String(), tt.want)
+		}
+	}
+}
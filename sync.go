@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"lazywhisper/config"
+	"lazywhisper/gitsync"
+	"lazywhisper/store"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type syncedMsg struct {
+	result *gitsync.Result
+	// conflicts are the ids of transcriptions edited here and on the remote
+	conflicts []string
+	err       error
+}
+
+type syncCommittedMsg struct{ err error }
+
+type syncTickMsg struct{}
+
+// syncKeyMap holds the bindings for syncing and resolving sync conflicts
+type syncKeyMap struct {
+	Now        key.Binding
+	KeepLocal  key.Binding
+	KeepRemote key.Binding
+}
+
+var syncKeys = syncKeyMap{
+	Now: key.NewBinding(
+		key.WithKeys("G"),
+		key.WithHelp("<G>", "Sync now"),
+	),
+	KeepLocal: key.NewBinding(
+		key.WithKeys("m"),
+		key.WithHelp("<m>", "Keep this machine's versions"),
+	),
+	KeepRemote: key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("<t>", "Take the remote's versions"),
+	),
+}
+
+// syncConflictHelp is shown while sync conflicts are listed
+var syncConflictHelp = []key.Binding{
+	syncKeys.KeepLocal,
+	syncKeys.KeepRemote,
+	key.NewBinding(key.WithKeys("esc"), key.WithHelp("<esc>", "Decide later")),
+}
+
+// openSync opens the git repository the transcriptions are synced with, or
+// returns nil when sync is off
+func openSync(settings config.SyncSettings, s *store.Store) (*gitsync.Repo, error) {
+	if !settings.Enabled {
+		return nil, nil
+	}
+	return gitsync.Open(filepath.Join(s.AppDataDir(), config.TranscriptionsDir), settings)
+}
+
+// syncTranscriptions syncs with the remote and loads the pulled changes into the
+// store, returning the ids of conflicting transcriptions when strategy is Abort
+func syncTranscriptions(repo *gitsync.Repo, s *store.Store, strategy gitsync.Strategy) (*gitsync.Result, []string, error) {
+	result, err := repo.Sync(strategy)
+	var conflict *gitsync.ConflictError
+	if errors.As(err, &conflict) {
+		return result, transcriptionIDs(conflict.Files), nil
+	}
+	if err != nil {
+		return result, nil, err
+	}
+	if ids := transcriptionIDs(result.Changed); len(ids) > 0 {
+		if err := s.Reload(ids...); err != nil {
+			return result, nil, fmt.Errorf("synced, but failed to load the changes: %w", err)
+		}
+	}
+	return result, nil, nil
+}
+
+// transcriptionIDs returns the distinct transcriptions the files belong to
+func transcriptionIDs(files []string) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, file := range files {
+		// The repository's own files, like .gitignore
+		if strings.HasPrefix(filepath.Base(file), ".") {
+			continue
+		}
+		id := store.IDFromPath(file)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func syncNow(repo *gitsync.Repo, s *store.Store, strategy gitsync.Strategy) tea.Cmd {
+	return func() tea.Msg {
+		result, conflicts, err := syncTranscriptions(repo, s, strategy)
+		return syncedMsg{result: result, conflicts: conflicts, err: err}
+	}
+}
+
+// syncTick schedules the next background sync
+func syncTick(interval time.Duration) tea.Cmd {
+	if interval <= 0 {
+		return nil
+	}
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return syncTickMsg{}
+	})
+}
+
+// commitChanges commits edits so they are pushed with the next sync
+func commitChanges(repo *gitsync.Repo, message string) tea.Cmd {
+	if repo == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		_, err := repo.Commit(message)
+		return syncCommittedMsg{err: err}
+	}
+}
+
+// startSync runs a sync unless one is already running
+func (m model) startSync(strategy gitsync.Strategy) (model, tea.Cmd) {
+	if m.sync == nil || m.syncing {
+		return m, nil
+	}
+	m.syncing = true
+	return m, syncNow(m.sync, m.store, strategy)
+}
+
+// handleSynced records the outcome of a sync and reloads the list when anything was pulled
+func (m model) handleSynced(msg syncedMsg) (model, tea.Cmd) {
+	m.syncing = false
+	m.syncErr = msg.err
+	m.syncConflicts = msg.conflicts
+	if msg.err == nil && len(msg.conflicts) == 0 {
+		m.lastSync = time.Now()
+		m.showingSyncConflicts = false
+	}
+	if m.showingTranscriptions && msg.result != nil && len(msg.result.Changed) > 0 {
+		return m, loadTranscriptions(m.store)
+	}
+	return m, nil
+}
+
+// handleSyncConflictsUpdate resolves the listed conflicts in favour of one side
+func (m model) handleSyncConflictsUpdate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, syncKeys.KeepLocal):
+		return m.startSync(gitsync.KeepLocal)
+	case key.Matches(msg, syncKeys.KeepRemote):
+		return m.startSync(gitsync.KeepRemote)
+	case key.Matches(msg, keys.Back):
+		m.showingSyncConflicts = false
+	case key.Matches(msg, keys.Quit):
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// syncConflictsView lists the transcriptions edited here and on the remote
+func (m model) syncConflictsView() string {
+	var b strings.Builder
+	b.WriteString("Sync conflicts:\n\n")
+	b.WriteString("These transcriptions were edited on this machine and on another one since the last sync.\n")
+	b.WriteString("Nothing has been merged yet.\n\n")
+	for _, id := range m.syncConflicts {
+		label := id
+		for _, entry := range m.allTranscriptions {
+			if entry.ID == id {
+				label = entryLabel(entry)
+				break
+			}
+		}
+		fmt.Fprintf(&b, "• %s\n", label)
+	}
+	if m.syncing {
+		b.WriteString("\nSyncing...")
+	} else if m.syncErr != nil {
+		b.WriteString("\n" + errorStyle.Render(fmt.Sprintf("Sync failed: %v", m.syncErr)))
+	}
+	return paddedStyle.Render(b.String())
+}
+
+// syncStatusLine summarizes the last sync, or returns an empty string when sync is off
+func (m model) syncStatusLine() string {
+	switch {
+	case m.sync == nil:
+		return ""
+	case m.syncing:
+		return "Syncing..."
+	case len(m.syncConflicts) > 0:
+		return fmt.Sprintf("Sync conflict in %d transcriptions; press G in the list to resolve", len(m.syncConflicts))
+	case m.syncErr != nil:
+		return fmt.Sprintf("Sync failed: %v", m.syncErr)
+	case m.lastSync.IsZero():
+		return "Not synced yet"
+	default:
+		return "Synced at " + m.lastSync.Format("15:04")
+	}
+}
+
+// runSync syncs the transcriptions with the configured remote once
+func runSync(args []string) int {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	keepLocal := fs.Bool("keep-local", false, "resolve conflicts with this machine's versions")
+	keepRemote := fs.Bool("keep-remote", false, "resolve conflicts with the remote's versions")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *keepLocal && *keepRemote {
+		fmt.Fprintf(os.Stderr, "--keep-local and --keep-remote can't be combined\n\n%s", usage)
+		return 2
+	}
+	strategy := gitsync.Abort
+	switch {
+	case *keepLocal:
+		strategy = gitsync.KeepLocal
+	case *keepRemote:
+		strategy = gitsync.KeepRemote
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if !settings.Sync.Enabled {
+		fmt.Fprintf(os.Stderr, "Error: set sync.enabled and sync.remote in %s first\n", config.SettingsFile)
+		return 1
+	}
+	transcriptions, err := openStore(settings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	repo, err := openSync(settings.Sync, transcriptions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	result, conflicts, err := syncTranscriptions(repo, transcriptions, strategy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(conflicts) > 0 {
+		for _, id := range conflicts {
+			fmt.Printf("conflict  %s\n", id)
+		}
+		fmt.Fprintln(os.Stderr, "Edited here and on the remote; run again with --keep-local or --keep-remote")
+		return 1
+	}
+	for _, id := range transcriptionIDs(result.Changed) {
+		fmt.Printf("pulled    %s\n", id)
+	}
+	fmt.Printf("Synced with %s\n", settings.Sync.Remote)
+	return 0
+}
+
+// syncInBackground syncs every interval until done is closed, reporting each
+// outcome through report. Used by watch mode, which has no TUI.
+func syncInBackground(repo *gitsync.Repo, s *store.Store, interval time.Duration, done <-chan struct{}, report func(string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			result, conflicts, err := syncTranscriptions(repo, s, gitsync.Abort)
+			switch {
+			case err != nil:
+				report(fmt.Sprintf("sync failed: %v", err))
+			case len(conflicts) > 0:
+				report(fmt.Sprintf("sync conflict in %s; run lazywhisper sync to resolve", strings.Join(conflicts, ", ")))
+			case len(result.Changed) > 0:
+				report(fmt.Sprintf("synced, pulled %d transcriptions", len(transcriptionIDs(result.Changed))))
+			}
+		}
+	}
+}
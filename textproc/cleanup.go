@@ -0,0 +1,236 @@
+package textproc
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultFillers are the filler words removed for each language. "*" applies
+// to every language. Lists in the config replace the entry for that language.
+// Phrases of more than one word are only removed when punctuation or the
+// start or end of a sentence sets them apart, so "Do you know the answer?"
+// keeps its "you know".
+var DefaultFillers = map[string][]string{
+	"*":  {"um", "umm", "uh", "uhh", "uhm", "mhm"},
+	"en": {"er", "erm", "ah", "you know"},
+	"es": {"eh", "em", "o sea"},
+	"fr": {"euh", "bah", "ben", "hein"},
+	"de": {"äh", "ähm", "öh", "öhm"},
+}
+
+// languageCodes maps the language names returned by the API to ISO-639-1 codes
+var languageCodes = map[string]string{
+	"english": "en",
+	"spanish": "es",
+	"french":  "fr",
+	"german":  "de",
+}
+
+// correctionMarkers are what speakers say after cutting themselves off, as in
+// "pick- I mean choose". They are removed along with the cut off word.
+var correctionMarkers = map[string][][]string{
+	"en": {{"i", "mean"}, {"sorry"}, {"or", "rather"}},
+}
+
+// keptRepeats are repeated words that are grammatical rather than a stutter
+var keptRepeats = map[string]bool{
+	"had had":   true,
+	"that that": true,
+	"bye bye":   true,
+}
+
+// maxRepeat is the longest run of words checked for stutters like "I think I think"
+const maxRepeat = 3
+
+// Cleaner removes filler words, stutters and false starts from dictation
+type Cleaner struct {
+	fillers map[string][][]string
+}
+
+// NewCleaner merges the configured filler lists over the defaults
+func NewCleaner(custom map[string][]string) *Cleaner {
+	merged := map[string][]string{}
+	for language, words := range DefaultFillers {
+		merged[language] = words
+	}
+	for language, words := range custom {
+		merged[strings.ToLower(language)] = words
+	}
+
+	c := &Cleaner{fillers: map[string][][]string{}}
+	for language, phrases := range merged {
+		for _, phrase := range phrases {
+			if words := strings.Fields(normalizePhrase(phrase)); len(words) > 0 {
+				c.fillers[language] = append(c.fillers[language], words)
+			}
+		}
+	}
+	return c
+}
+
+// Apply cleans text using the filler list for language plus the shared list
+func (c *Cleaner) Apply(text, language string) string {
+	language = strings.ToLower(language)
+	if code, ok := languageCodes[language]; ok {
+		language = code
+	}
+	fillers := append(append([][]string(nil), c.fillers["*"]...), c.fillers[language]...)
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		words := removeFalseStarts(strings.Fields(line), correctionMarkers[language])
+		words = removeFillers(words, fillers)
+		words = removeRepeats(words)
+		lines[i] = strings.Join(words, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// removeFalseStarts drops words cut off mid way, which Whisper writes with a
+// trailing dash ("I was- I went" or "I--I went"), along with a correction
+// marker right after them ("pick- I mean choose"). Restarts without a dash
+// are left alone, since they can't be told apart from ordinary speech.
+func removeFalseStarts(words []string, markers [][]string) []string {
+	var kept []string
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if j := strings.LastIndex(word, "--"); j >= 0 && j+2 < len(word) {
+			word = word[j+2:]
+		}
+		// A lone dash is a list bullet, not a cut off word
+		if !strings.HasSuffix(word, "-") || strings.Trim(word, "-") == "" {
+			kept = append(kept, word)
+			continue
+		}
+
+		next := i + 1 + matchPhrase(words[i+1:], markers)
+		// The cut off word started the sentence, so the correction does now
+		if next < len(words) && startsUpper(word) && (len(kept) == 0 || endsSentenceWord(kept[len(kept)-1])) {
+			words[next] = capitalize(words[next])
+		}
+		i = next - 1
+	}
+	return kept
+}
+
+func removeFillers(words []string, fillers [][]string) []string {
+	var kept []string
+	for i := 0; i < len(words); {
+		n := matchPhrase(words[i:], fillers)
+		// Phrases like "you know" are only fillers when set apart from the sentence
+		if n > 1 && !setApart(kept, words[i:i+n], i+n == len(words)) {
+			n = 0
+		}
+		if n == 0 {
+			kept = append(kept, words[i])
+			i++
+			continue
+		}
+
+		removed := words[i : i+n]
+		i += n
+
+		if len(kept) > 0 {
+			previous := kept[len(kept)-1]
+			if end := trailingPunctuation(removed[n-1]); end != "" {
+				// Keep the sentence ending punctuation of a trailing filler ("went home, um.")
+				kept[len(kept)-1] = strings.TrimRight(previous, ",;:") + end
+			} else if strings.HasSuffix(removed[n-1], ",") && strings.HasSuffix(previous, ",") {
+				// The commas around a filler were only there for the pause ("and, uh, bought")
+				kept[len(kept)-1] = strings.TrimSuffix(previous, ",")
+			}
+		}
+		// A filler that started a sentence hands its capital to the next word
+		if startsUpper(removed[0]) && i < len(words) && (len(kept) == 0 || endsSentenceWord(kept[len(kept)-1])) {
+			words[i] = capitalize(words[i])
+		}
+	}
+	return kept
+}
+
+// matchPhrase returns the number of words in the longest of phrases at the start of words
+func matchPhrase(words []string, phrases [][]string) int {
+	longest := 0
+	for _, phrase := range phrases {
+		if len(phrase) > len(words) || len(phrase) <= longest {
+			continue
+		}
+		matched := true
+		for i, word := range phrase {
+			if normalizeWord(words[i]) != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			longest = len(phrase)
+		}
+	}
+	return longest
+}
+
+// setApart reports whether phrase is separated from the words around it by
+// punctuation or the start or end of the line
+func setApart(before, phrase []string, atEnd bool) bool {
+	startsApart := len(before) == 0 || hasTrailingMark(before[len(before)-1])
+	endsApart := atEnd || hasTrailingMark(phrase[len(phrase)-1])
+	return startsApart && endsApart
+}
+
+// hasTrailingMark reports whether a word ends with punctuation
+func hasTrailingMark(word string) bool {
+	r, _ := utf8.DecodeLastRuneInString(word)
+	return strings.ContainsRune(",;:.!?", r)
+}
+
+// removeRepeats collapses immediately repeated words or short phrases. The
+// first copy is kept with its case, taking the punctuation of the last copy,
+// which is what continues the sentence.
+func removeRepeats(words []string) []string {
+	for n := maxRepeat; n >= 1; n-- {
+		var kept []string
+		for i := 0; i < len(words); {
+			if i+2*n <= len(words) && samePhrase(words[i:i+n], words[i+n:i+2*n]) {
+				first := append([]string(nil), words[i:i+n]...)
+				last := words[i+2*n-1]
+				first[n-1] = strings.TrimRight(first[n-1], ",;:") + last[len(strings.TrimRight(last, ",;:.!?")):]
+				// Replace the second copy so longer runs keep collapsing into the first
+				copy(words[i+n:i+2*n], first)
+				i += n
+				continue
+			}
+			kept = append(kept, words[i])
+			i++
+		}
+		words = kept
+	}
+	return words
+}
+
+func samePhrase(a, b []string) bool {
+	if len(a) == 1 && keptRepeats[normalizeWord(a[0])+" "+normalizeWord(b[0])] {
+		return false
+	}
+	for i := range a {
+		if normalizeWord(a[i]) == "" || normalizeWord(a[i]) != normalizeWord(b[i]) {
+			return false
+		}
+	}
+	// A sentence break between the copies is deliberate ("No. No, I meant...")
+	return !endsSentenceWord(a[len(a)-1])
+}
+
+func endsSentenceWord(word string) bool {
+	return trailingPunctuation(word) != ""
+}
+
+func startsUpper(word string) bool {
+	r, _ := utf8.DecodeRuneInString(word)
+	return unicode.IsUpper(r)
+}
+
+func capitalize(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + word[size:]
+}
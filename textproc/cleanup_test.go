@@ -0,0 +1,78 @@
+package textproc
+
+import "testing"
+
+func TestCleanerApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		language string
+		want     string
+	}{
+		// Fillers
+		{"filler at the start", "Um, I went home.", "en", "I went home."},
+		{"filler between commas", "I went, uh, home.", "en", "I went home."},
+		{"filler before the full stop", "It was fine, um.", "en", "It was fine."},
+		{"filler phrase set apart", "I went, you know, home.", "en", "I went home."},
+		{"filler phrase starting a sentence", "You know, it's fine.", "en", "It's fine."},
+		{"filler phrase ending a sentence", "It was fine, you know.", "en", "It was fine."},
+		{"filler phrase in a question", "Do you know the answer?", "en", "Do you know the answer?"},
+		{"filler phrase as an object", "Tell me what you know about it.", "en", "Tell me what you know about it."},
+		{"units aren't fillers", "It is 5 mm long.", "en", "It is 5 mm long."},
+		{"hmm is kept", "Hmm, let me think.", "en", "Hmm, let me think."},
+		{"language names map to codes", "Fue, o sea, bueno.", "spanish", "Fue bueno."},
+		{"other languages' fillers are kept", "Ben oui.", "en", "Ben oui."},
+
+		// Repeats
+		{"repeated word", "I I went home.", "en", "I went home."},
+		{"repeat keeps the first copy's case", "The the cat sat.", "en", "The cat sat."},
+		{"repeated phrase", "I think, I think we should go.", "en", "I think we should go."},
+		{"run of repeats", "We should go go go now.", "en", "We should go now."},
+		{"repeat takes the last copy's comma", "Well well, fine.", "en", "Well, fine."},
+		{"sentence break between copies", "No. No, I meant it.", "en", "No. No, I meant it."},
+		{"had had", "She had had enough.", "en", "She had had enough."},
+		{"that that", "I know that that is true.", "en", "I know that that is true."},
+		{"that that starting a sentence", "That that is right.", "en", "That that is right."},
+		{"bye bye", "Bye bye.", "en", "Bye bye."},
+
+		// False starts
+		{"cut off word", "I was- I went home.", "en", "I went home."},
+		{"double dash", "I--I went.", "en", "I went."},
+		{"correction marker", "I was going to pick- I mean choose the blue one.", "en", "I was going to choose the blue one."},
+		{"correction marker starting a sentence", "Pick- sorry, choose one.", "en", "Choose one."},
+		{"or rather", "It costs ten- or rather twelve dollars.", "en", "It costs twelve dollars."},
+		{"markers without a cut off word are kept", "I mean it.", "en", "I mean it."},
+		{"lone dash is a bullet", "Items: - eggs", "en", "Items: - eggs"},
+
+		// Lines
+		{"each line is cleaned", "Um, first.\nUh, second.", "en", "First.\nSecond."},
+	}
+
+	c := NewCleaner(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Apply(tt.in, tt.language); got != tt.want {
+				t.Errorf("Apply(%q, %q) = %q, want %q", tt.in, tt.language, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanerCustomFillers(t *testing.T) {
+	c := NewCleaner(map[string][]string{"EN": {"like", "sort of"}})
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"It was, like, fine.", "It was fine."},
+		// The custom list replaces the built-in English one
+		{"It was, er, fine.", "It was, er, fine."},
+		{"What sort of thing?", "What sort of thing?"},
+		{"It was, sort of, fine.", "It was fine."},
+	}
+	for _, tt := range tests {
+		if got := c.Apply(tt.in, "en"); got != tt.want {
+			t.Errorf("Apply(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package textproc
+
+import (
+	"fmt"
+	"lazywhisper/config"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// ActionInsert replaces the spoken phrase with the command's text
+	ActionInsert = "insert"
+	// ActionScratch removes the previous sentence, or undoes the previous command
+	ActionScratch = "scratch"
+)
+
+// DefaultCommands are always available. Commands in the config with the same
+// phrase replace these.
+var DefaultCommands = []config.SpokenCommand{
+	{Phrase: "new line", Insert: "\n"},
+	{Phrase: "new paragraph", Insert: "\n\n"},
+	{Phrase: "bullet point", Insert: "\n- "},
+	{Phrase: "open quote", Insert: `"`, AttachNext: true},
+	{Phrase: "close quote", Insert: `"`, AttachPrevious: true},
+	{Phrase: "end quote", Insert: `"`, AttachPrevious: true},
+	{Phrase: "scratch that", Action: ActionScratch},
+}
+
+// CommandProcessor turns spoken formatting commands like "new line" or
+// "scratch that" into formatting and edits
+type CommandProcessor struct {
+	commands []command
+}
+
+type command struct {
+	words          []string
+	action         string
+	insert         string
+	attachPrevious bool
+	attachNext     bool
+}
+
+// piece is a word or inserted text in the output
+type piece struct {
+	text string
+	// space is the whitespace before the piece in the original text, so line
+	// breaks already there survive
+	space string
+	// fromCommand marks text inserted by a command, which "scratch that" removes first
+	fromCommand   bool
+	noSpaceBefore bool
+	noSpaceAfter  bool
+}
+
+// NewCommandProcessor merges the configured commands over the defaults
+func NewCommandProcessor(custom []config.SpokenCommand) (*CommandProcessor, error) {
+	byPhrase := map[string]config.SpokenCommand{}
+	for _, c := range DefaultCommands {
+		byPhrase[normalizePhrase(c.Phrase)] = c
+	}
+	for i, c := range custom {
+		phrase := normalizePhrase(c.Phrase)
+		if phrase == "" {
+			return nil, fmt.Errorf("spoken command %d has an empty phrase", i+1)
+		}
+		switch c.Action {
+		case "", ActionInsert, ActionScratch:
+		default:
+			return nil, fmt.Errorf("spoken command %q has unknown action %q", c.Phrase, c.Action)
+		}
+		byPhrase[phrase] = c
+	}
+
+	p := &CommandProcessor{}
+	for phrase, c := range byPhrase {
+		action := c.Action
+		if action == "" {
+			action = ActionInsert
+		}
+		p.commands = append(p.commands, command{
+			words:          strings.Fields(phrase),
+			action:         action,
+			insert:         c.Insert,
+			attachPrevious: c.AttachPrevious || strings.TrimLeft(c.Insert, " \n\t") != c.Insert,
+			attachNext:     c.AttachNext || strings.TrimRight(c.Insert, " \n\t") != c.Insert,
+		})
+	}
+
+	// Try longer phrases first so "new paragraph" isn't shadowed by a shorter command
+	sort.Slice(p.commands, func(i, j int) bool {
+		if len(p.commands[i].words) != len(p.commands[j].words) {
+			return len(p.commands[i].words) > len(p.commands[j].words)
+		}
+		return strings.Join(p.commands[i].words, " ") < strings.Join(p.commands[j].words, " ")
+	})
+
+	return p, nil
+}
+
+// Apply replaces spoken commands in text. Commands are the same in every language.
+func (p *CommandProcessor) Apply(text, _ string) string {
+	tokens, spaces := splitWords(text)
+	normalized := make([]string, len(tokens))
+	for i, token := range tokens {
+		normalized[i] = normalizeWord(token)
+	}
+
+	var pieces []piece
+	for i := 0; i < len(tokens); {
+		c, ok := p.match(normalized[i:])
+		if !ok {
+			pieces = append(pieces, piece{text: tokens[i], space: spaces[i]})
+			i++
+			continue
+		}
+
+		space := spaces[i]
+		last := tokens[i+len(c.words)-1]
+		i += len(c.words)
+
+		switch c.action {
+		case ActionScratch:
+			pieces = scratch(pieces)
+		default:
+			insert := c.insert
+			// Punctuation after a line break command is Whisper's, but after an
+			// inline insert like a closing quote it ends the user's sentence
+			if !strings.Contains(insert, "\n") {
+				insert += trailingPunctuation(last)
+			}
+			// Whisper marks the pause before a command with a comma, which would
+			// otherwise end up inside a closing quote
+			if c.attachPrevious && len(pieces) > 0 && !pieces[len(pieces)-1].fromCommand {
+				pieces[len(pieces)-1].text = strings.TrimSuffix(pieces[len(pieces)-1].text, ",")
+			}
+			pieces = append(pieces, piece{
+				text:          insert,
+				space:         space,
+				fromCommand:   true,
+				noSpaceBefore: c.attachPrevious,
+				noSpaceAfter:  c.attachNext,
+			})
+		}
+	}
+
+	return render(pieces)
+}
+
+func (p *CommandProcessor) match(words []string) (command, bool) {
+	for _, c := range p.commands {
+		if len(c.words) > len(words) {
+			continue
+		}
+		matched := true
+		for i, word := range c.words {
+			if words[i] != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// scratch undoes the last command, or removes words back to the end of the
+// previous sentence. If the current sentence is already finished, the whole
+// sentence is removed.
+func scratch(pieces []piece) []piece {
+	if len(pieces) == 0 {
+		return pieces
+	}
+	last := len(pieces) - 1
+	if pieces[last].fromCommand {
+		return pieces[:last]
+	}
+
+	i := last
+	if endsSentence(pieces[i]) {
+		i--
+	}
+	for i >= 0 && !endsSentence(pieces[i]) && !pieces[i].fromCommand {
+		i--
+	}
+	return pieces[:i+1]
+}
+
+func endsSentence(p piece) bool {
+	if p.fromCommand {
+		return false
+	}
+	return strings.HasSuffix(p.text, ".") || strings.HasSuffix(p.text, "!") || strings.HasSuffix(p.text, "?")
+}
+
+func render(pieces []piece) string {
+	var b strings.Builder
+	for i, p := range pieces {
+		switch {
+		case i == 0:
+		case pieces[i-1].noSpaceAfter || p.noSpaceBefore:
+			// Attached pieces drop the spaces between them but keep line breaks
+			b.WriteString(strings.Repeat("\n", strings.Count(p.space, "\n")))
+		case p.space == "":
+			b.WriteString(" ")
+		default:
+			b.WriteString(p.space)
+		}
+		b.WriteString(p.text)
+	}
+
+	// Drop spaces left dangling at the end of lines
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// splitWords splits text at whitespace like strings.Fields, also returning
+// the whitespace before each word
+func splitWords(text string) ([]string, []string) {
+	var words, spaces []string
+	rest := text
+	for {
+		trimmed := strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if trimmed == "" {
+			return words, spaces
+		}
+		end := strings.IndexFunc(trimmed, unicode.IsSpace)
+		if end < 0 {
+			end = len(trimmed)
+		}
+		spaces = append(spaces, rest[:len(rest)-len(trimmed)])
+		words = append(words, trimmed[:end])
+		rest = trimmed[end:]
+	}
+}
+
+// trailingPunctuation returns the sentence ending punctuation at the end of token
+func trailingPunctuation(token string) string {
+	trimmed := strings.TrimRight(token, ".!?")
+	return token[len(trimmed):]
+}
+
+// normalizeWord lowercases a token and strips the punctuation Whisper adds around it
+func normalizeWord(token string) string {
+	return strings.ToLower(strings.Trim(token, `.,!?;:"'()`))
+}
+
+func normalizePhrase(phrase string) string {
+	words := strings.Fields(phrase)
+	for i, word := range words {
+		words[i] = normalizeWord(word)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,95 @@
+package textproc
+
+import (
+	"lazywhisper/config"
+	"testing"
+)
+
+func TestCommandProcessorApply(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// Every default command
+		{"new line", "first line new line second line", "first line\nsecond line"},
+		{"new paragraph", "Hello. New paragraph. Next part.", "Hello.\n\nNext part."},
+		{"bullet point", "Shopping list bullet point eggs bullet point milk", "Shopping list\n- eggs\n- milk"},
+		{"open and close quote", "He said open quote hello close quote and left", `He said "hello" and left`},
+		{"end quote", "He said open quote hello end quote and left", `He said "hello" and left`},
+		{"scratch that removes a finished sentence", "This is wrong. Scratch that. This is right.", "This is right."},
+		{"scratch that removes an unfinished sentence", "Buy milk. And eggs scratch that", "Buy milk."},
+		{"scratch that undoes a command", "hello new line scratch that world", "hello world"},
+		{"scratch that with nothing before it", "scratch that hello", "hello"},
+		{"scratch that twice", "One. Two. Scratch that. Scratch that. Three.", "Three."},
+
+		// Quotes
+		{"nested quotes", "open quote he said open quote hi close quote close quote", `"he said "hi""`},
+		{"unclosed quote", "open quote never closed", `"never closed`},
+		{"close quote without an open quote", "stray close quote here", `stray" here`},
+		{"quote punctuation moves outside", "She said, open quote, stop, end quote.", `She said, "stop".`},
+
+		// Case and punctuation around the trigger words
+		{"upper case", "first NEW LINE second", "first\nsecond"},
+		{"title case with punctuation", "first, New Line. second", "first\nsecond"},
+		{"parentheses", "first (new line) second", "first\nsecond"},
+		{"question mark after close quote", "Did he say open quote yes close quote?", `Did he say "yes"?`},
+		{"trigger inside a longer word", "renew line items", "renew line items"},
+		{"trigger split by other words", "new shiny line", "new shiny line"},
+
+		// Whitespace already in the text
+		{"line breaks are kept", "line one\nline two new line line three", "line one\nline two\nline three"},
+		{"paragraphs are kept", "para one\n\npara two", "para one\n\npara two"},
+		{"line break before a command", "foo\nnew line bar", "foo\n\nbar"},
+		{"no commands", "  plain   text  ", "plain   text"},
+	}
+
+	p, err := NewCommandProcessor(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Apply(tt.in, "en"); got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandProcessorCustomCommands(t *testing.T) {
+	p, err := NewCommandProcessor([]config.SpokenCommand{
+		{Phrase: "open paren", Insert: "(", AttachNext: true},
+		{Phrase: "close paren", Insert: ")", AttachPrevious: true},
+		{Phrase: "delete that", Action: ActionScratch},
+		// Overrides the built-in command
+		{Phrase: "New Line", Insert: " / "},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"call open paren maybe close paren later", "call (maybe) later"},
+		{"Keep this. Not this delete that", "Keep this."},
+		{"a new line b", "a / b"},
+	}
+	for _, tt := range tests {
+		if got := p.Apply(tt.in, "en"); got != tt.want {
+			t.Errorf("Apply(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNewCommandProcessorRejectsInvalidCommands(t *testing.T) {
+	for _, c := range []config.SpokenCommand{
+		{Phrase: "  ", Insert: "x"},
+		{Phrase: "zap", Action: "explode"},
+	} {
+		if _, err := NewCommandProcessor([]config.SpokenCommand{c}); err == nil {
+			t.Errorf("NewCommandProcessor(%+v) succeeded, want an error", c)
+		}
+	}
+}
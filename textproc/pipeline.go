@@ -0,0 +1,16 @@
+package textproc
+
+// Stage is a single deterministic post-processing step applied to transcribed text
+type Stage interface {
+	Apply(text, language string) string
+}
+
+// Pipeline runs its stages in order
+type Pipeline []Stage
+
+func (p Pipeline) Apply(text, language string) string {
+	for _, stage := range p {
+		text = stage.Apply(text, language)
+	}
+	return text
+}
@@ -0,0 +1,99 @@
+package textproc
+
+import (
+	"fmt"
+	"lazywhisper/config"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Replacer applies the find/replace dictionary from the config, in order
+type Replacer struct {
+	rules []*rule
+}
+
+type rule struct {
+	pattern *regexp.Regexp
+	replace string
+	// wholeWord skips matches with a word character right before or after them
+	wholeWord bool
+}
+
+// NewReplacer compiles the configured replacements. Plain rules are matched
+// literally; regex rules may use $1 style references in the replacement.
+func NewReplacer(replacements []config.Replacement) (*Replacer, error) {
+	r := &Replacer{}
+	for i, replacement := range replacements {
+		if replacement.Find == "" {
+			return nil, fmt.Errorf("replacement %d has an empty find", i+1)
+		}
+
+		expr := replacement.Find
+		replace := replacement.Replace
+		if !replacement.Regex {
+			expr = regexp.QuoteMeta(expr)
+			// Keep a literal $ in the replacement from being read as a group reference
+			replace = escapeDollars(replace)
+		}
+
+		if !replacement.CaseSensitive {
+			expr = "(?i)" + expr
+		}
+
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("replacement %d (%q) is not a valid pattern: %w", i+1, replacement.Find, err)
+		}
+		r.rules = append(r.rules, &rule{pattern: pattern, replace: replace, wholeWord: replacement.WholeWord})
+	}
+	return r, nil
+}
+
+// Apply runs every rule over text regardless of language
+func (r *Replacer) Apply(text, _ string) string {
+	for _, rule := range r.rules {
+		text = rule.apply(text)
+	}
+	return text
+}
+
+func (r *rule) apply(text string) string {
+	if !r.wholeWord {
+		return r.pattern.ReplaceAllString(text, r.replace)
+	}
+	var b strings.Builder
+	last := 0
+	for _, match := range r.pattern.FindAllStringSubmatchIndex(text, -1) {
+		if !isWholeWord(text, match[0], match[1]) {
+			continue
+		}
+		b.WriteString(text[last:match[0]])
+		b.Write(r.pattern.ExpandString(nil, r.replace, text, match))
+		last = match[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// isWholeWord reports whether text[start:end] has no word character right
+// before or after it. Unlike \b this works for finds that begin or end with
+// punctuation, so "C++" doesn't match inside "C++11".
+func isWholeWord(text string, start, end int) bool {
+	if before, size := utf8.DecodeLastRuneInString(text[:start]); size > 0 && isWordRune(before) {
+		return false
+	}
+	if after, size := utf8.DecodeRuneInString(text[end:]); size > 0 && isWordRune(after) {
+		return false
+	}
+	return true
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func escapeDollars(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
@@ -0,0 +1,69 @@
+package textproc
+
+import (
+	"lazywhisper/config"
+	"testing"
+)
+
+func TestReplacerWholeWord(t *testing.T) {
+	tests := []struct {
+		name        string
+		replacement config.Replacement
+		in          string
+		want        string
+	}{
+		{
+			name:        "word finds skip longer words",
+			replacement: config.Replacement{Find: "cat", Replace: "dog", WholeWord: true},
+			in:          "cat category concat cat.",
+			want:        "dog category concat dog.",
+		},
+		{
+			name:        "punctuation finds don't match inside a longer token",
+			replacement: config.Replacement{Find: "C++", Replace: "cpp", WholeWord: true},
+			in:          "C++ and C++11 and xC++",
+			want:        "cpp and C++11 and xC++",
+		},
+		{
+			name:        "punctuation finds match next to punctuation",
+			replacement: config.Replacement{Find: "C++", Replace: "cpp", WholeWord: true},
+			in:          "(C++), C++!",
+			want:        "(cpp), cpp!",
+		},
+		{
+			name:        "non-ASCII letters are word characters",
+			replacement: config.Replacement{Find: "caf", Replace: "X", WholeWord: true},
+			in:          "café caf",
+			want:        "café X",
+		},
+		{
+			name:        "case insensitive by default",
+			replacement: config.Replacement{Find: "lazy whisper", Replace: "lazywhisper", WholeWord: true},
+			in:          "Lazy Whisper works",
+			want:        "lazywhisper works",
+		},
+		{
+			name:        "regex groups are expanded",
+			replacement: config.Replacement{Find: `(\d+) bucks`, Replace: "$$$1", Regex: true, WholeWord: true},
+			in:          "10 bucks, a10 bucks",
+			want:        "$10, a10 bucks",
+		},
+		{
+			name:        "literal dollars in the replacement",
+			replacement: config.Replacement{Find: "ten dollars", Replace: "$10", WholeWord: true},
+			in:          "ten dollars",
+			want:        "$10",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReplacer([]config.Replacement{tt.replacement})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.Apply(tt.in, "en"); got != tt.want {
+				t.Errorf("Apply(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"lazywhisper/config"
+	"lazywhisper/store"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// undoWindow is how long a delete can be undone from the list
+const undoWindow = 5 * time.Second
+
+type trashedMsg struct {
+	id  string
+	err error
+}
+
+// undoExpiredMsg closes the undo window opened by the delete with the same generation
+type undoExpiredMsg struct {
+	generation int
+}
+
+type trashLoadedMsg struct {
+	entries []store.TrashedEntry
+	err     error
+}
+
+// trashKeyMap holds the bindings that only apply in the trash view
+type trashKeyMap struct {
+	Open    key.Binding
+	Restore key.Binding
+	Purge   key.Binding
+	Undo    key.Binding
+}
+
+var trashKeys = trashKeyMap{
+	Open: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("<x>", "Open trash"),
+	),
+	Restore: key.NewBinding(
+		key.WithKeys("r", "enter"),
+		key.WithHelp("<r>", "Restore"),
+	),
+	Purge: key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("<d>", "Delete forever"),
+	),
+	Undo: key.NewBinding(
+		key.WithKeys("z"),
+		key.WithHelp("<z>", "Undo delete"),
+	),
+}
+
+// purgeExpiredTrash removes transcriptions trashed longer ago than the configured number of days
+func purgeExpiredTrash(s *store.Store, settings config.TrashSettings) error {
+	if settings.PurgeAfterDays == 0 {
+		return nil
+	}
+	_, err := s.PurgeTrash(time.Duration(settings.PurgeAfterDays) * 24 * time.Hour)
+	return err
+}
+
+// trashTranscription moves a transcription to the trash
+func trashTranscription(s *store.Store, id string) tea.Cmd {
+	return func() tea.Msg {
+		return trashedMsg{id: id, err: s.Trash(id)}
+	}
+}
+
+// offerUndo opens the window for undoing the delete of ids
+func (m model) offerUndo(ids []string) (model, tea.Cmd) {
+	m.lastTrashed = ids
+	m.undoGeneration++
+	generation := m.undoGeneration
+	return m, tea.Tick(undoWindow, func(time.Time) tea.Msg {
+		return undoExpiredMsg{generation: generation}
+	})
+}
+
+// restoreTranscriptions moves transcriptions out of the trash and reloads the list
+func restoreTranscriptions(s *store.Store, ids ...string) tea.Cmd {
+	return func() tea.Msg {
+		for _, id := range ids {
+			if err := s.Restore(id); err != nil {
+				return errMsg(err)
+			}
+		}
+		return loadTranscriptions(s)()
+	}
+}
+
+func loadTrash(s *store.Store) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := s.Trashed()
+		return trashLoadedMsg{entries: entries, err: err}
+	}
+}
+
+func purgeTranscription(s *store.Store, id string) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Purge(id); err != nil {
+			return trashLoadedMsg{err: err}
+		}
+		return loadTrash(s)()
+	}
+}
+
+// handleTrashUpdate restores or purges items in the trash view
+func (m model) handleTrashUpdate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.confirmingPurge {
+		switch {
+		case key.Matches(msg, keys.Confirm):
+			m.confirmingPurge = false
+			if len(m.trashEntries) > 0 {
+				return m, purgeTranscription(m.store, m.trashEntries[m.trashIndex].ID)
+			}
+		case key.Matches(msg, keys.Back):
+			m.confirmingPurge = false
+		}
+		return m, nil
+	}
+
+	switch {
+	case key.Matches(msg, keys.Quit):
+		return m, tea.Quit
+
+	case key.Matches(msg, keys.Up):
+		m.trashIndex = max(m.trashIndex-1, 0)
+
+	case key.Matches(msg, keys.Down):
+		m.trashIndex = max(min(m.trashIndex+1, len(m.trashEntries)-1), 0)
+
+	case key.Matches(msg, trashKeys.Restore):
+		if len(m.trashEntries) > 0 {
+			id := m.trashEntries[m.trashIndex].ID
+			return m, tea.Batch(restoreTranscriptions(m.store, id), loadTrash(m.store))
+		}
+
+	case key.Matches(msg, trashKeys.Purge):
+		if len(m.trashEntries) > 0 {
+			m.confirmingPurge = true
+		}
+
+	case key.Matches(msg, keys.Back), key.Matches(msg, trashKeys.Open):
+		m.showingTrash = false
+	}
+	return m, nil
+}
+
+// trashView lists trashed transcriptions with when they were deleted
+func (m model) trashView() string {
+	if len(m.trashEntries) == 0 {
+		return paddedStyle.Render("The trash is empty.\n\nPress ESC to go back")
+	}
+
+	if m.confirmingPurge {
+		return paddedStyle.Render(fmt.Sprintf(
+			"Permanently delete %s and its recording?\n\nPress ENTER to confirm or ESC to cancel",
+			entryLabel(m.trashEntries[m.trashIndex].Entry),
+		))
+	}
+
+	var b strings.Builder
+	b.WriteString("Trash:\n\n")
+	for i, entry := range m.trashEntries {
+		prefix := "  "
+		if i == m.trashIndex {
+			prefix = "▶ "
+		}
+		fmt.Fprintf(&b, "%s%s  %s\n", prefix, entryLabel(entry.Entry),
+			helpStyle.Render("deleted "+entry.DeletedAt.Format("Jan 2 15:04")))
+	}
+	if m.purgeAfterDays > 0 {
+		fmt.Fprintf(&b, "\n%s", helpStyle.Render(fmt.Sprintf("Items are deleted forever after %d days", m.purgeAfterDays)))
+	}
+	return paddedStyle.Render(b.String())
+}
+
+// undoLine offers to undo the most recent delete while the window is open
+func (m model) undoLine() string {
+	switch len(m.lastTrashed) {
+	case 0:
+		return ""
+	case 1:
+		return "\n\n" + successStyle.Render(fmt.Sprintf("Moved %s to trash. Press z to undo", m.lastTrashed[0]))
+	default:
+		return "\n\n" + successStyle.Render(fmt.Sprintf("Moved %d transcriptions to trash. Press z to undo", len(m.lastTrashed)))
+	}
+}
+
+var trashHelp = []key.Binding{keys.Up, keys.Down, trashKeys.Restore, trashKeys.Purge, keys.Back}
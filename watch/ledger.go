@@ -0,0 +1,74 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const ledgerFile = "watch_ledger.json"
+
+// ledgerEntry records where an imported file came from
+type ledgerEntry struct {
+	Source     string    `json:"source"`
+	ID         string    `json:"id"`
+	ImportedAt time.Time `json:"imported_at"`
+}
+
+// ledger maps the sha256 of every imported file to its recording so the same
+// memo is never imported twice, even if it is renamed or synced again
+type ledger struct {
+	path    string
+	entries map[string]ledgerEntry
+}
+
+func loadLedger(path string) (*ledger, error) {
+	l := &ledger{path: path, entries: map[string]ledgerEntry{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch ledger: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.entries); err != nil {
+		return nil, fmt.Errorf("failed to parse watch ledger: %w", err)
+	}
+	return l, nil
+}
+
+func (l *ledger) has(hash string) bool {
+	_, ok := l.entries[hash]
+	return ok
+}
+
+func (l *ledger) add(hash string, entry ledgerEntry) error {
+	l.entries[hash] = entry
+
+	data, err := json.MarshalIndent(l.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode watch ledger: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated ledger
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write watch ledger: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to write watch ledger: %w", err)
+	}
+	return nil
+}
+
+func entryFor(source, id string) ledgerEntry {
+	return ledgerEntry{
+		Source:     filepath.Base(source),
+		ID:         id,
+		ImportedAt: time.Now(),
+	}
+}
@@ -0,0 +1,442 @@
+package watch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"lazywhisper/audio"
+	"lazywhisper/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// audioExtensions are the file types picked up from the watch folder
+var audioExtensions = map[string]bool{
+	".wav":  true,
+	".mp3":  true,
+	".m4a":  true,
+	".mp4":  true,
+	".mpeg": true,
+	".mpga": true,
+	".webm": true,
+	".ogg":  true,
+	".flac": true,
+	".aac":  true,
+}
+
+type EventKind int
+
+const (
+	Queued EventKind = iota
+	Started
+	Completed
+	Failed
+)
+
+// Event reports progress for a single file picked up from the watch folder
+type Event struct {
+	Kind   EventKind
+	Source string
+	ID     string
+	Text   string
+	Err    error
+}
+
+// Status is a snapshot of the work currently in flight
+type Status struct {
+	Queued     int
+	Processing int
+}
+
+// Failed files are retried after firstRetry, doubling up to maxRetry
+const (
+	firstRetry = time.Minute
+	maxRetry   = time.Hour
+)
+
+type job struct {
+	source string
+	hash   string
+}
+
+// fileState tracks the size and modification time of a file between scans so
+// we only import files that have finished syncing
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// retry tracks a file whose import failed so it is tried again later
+type retry struct {
+	attempts int
+	at       time.Time
+}
+
+type Watcher struct {
+	dir         string
+	interval    time.Duration
+	workers     int
+	appDataDir  string
+	transcriber *audio.Transcriber
+	options     audio.TranscribeOptions
+	ledger      *ledger
+	// importDir is where files are converted when they mustn't be written to
+	// the app data directory in plaintext. Empty converts into the recordings directory.
+	importDir string
+
+	jobs   chan job
+	events chan Event
+
+	mu       sync.Mutex
+	pending  map[string]fileState
+	handled  map[string]bool
+	inFlight map[string]bool
+	retries  map[string]retry
+
+	queued     int32
+	processing int32
+}
+
+// New creates a watcher for the folder configured in settings. Imported files
+// are transcribed in the configured default language.
+func New(all *config.Settings, transcriber *audio.Transcriber) (*Watcher, error) {
+	settings := all.Watch
+	if settings.Dir == "" {
+		return nil, fmt.Errorf("no watch directory configured")
+	}
+	info, err := os.Stat(settings.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watch directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("watch path %s is not a directory", settings.Dir)
+	}
+
+	appDataDir, err := config.GetAppDataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := loadLedger(filepath.Join(appDataDir, ledgerFile))
+	if err != nil {
+		return nil, err
+	}
+
+	// With encryption on, the store encrypts recordings into the recordings
+	// directory once they are transcribed
+	var importDir string
+	if all.Encryption.Enabled {
+		if importDir, err = config.TempDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Watcher{
+		dir:         settings.Dir,
+		interval:    time.Duration(settings.IntervalSeconds) * time.Second,
+		workers:     settings.Workers,
+		appDataDir:  appDataDir,
+		transcriber: transcriber,
+		options:     audio.TranscribeOptions{Language: all.Transcription.Language},
+		ledger:      l,
+		importDir:   importDir,
+		jobs:        make(chan job, settings.Workers),
+		events:      make(chan Event, 64),
+		pending:     map[string]fileState{},
+		handled:     map[string]bool{},
+		inFlight:    map[string]bool{},
+		retries:     map[string]retry{},
+	}, nil
+}
+
+// Dir returns the folder being watched
+func (w *Watcher) Dir() string {
+	return w.dir
+}
+
+// Events returns a channel of progress updates. Events are dropped if nobody is reading.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Status returns the number of files waiting for and currently being processed
+func (w *Watcher) Status() Status {
+	return Status{
+		Queued:     int(atomic.LoadInt32(&w.queued)),
+		Processing: int(atomic.LoadInt32(&w.processing)),
+	}
+}
+
+// Run scans the folder and processes new files until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work(ctx)
+		}()
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.scan(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			w.drain()
+			return
+		case <-ticker.C:
+			w.scan(ctx)
+		}
+	}
+}
+
+func (w *Watcher) scan(ctx context.Context) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		w.emit(Event{Kind: Failed, Source: w.dir, Err: fmt.Errorf("failed to read watch directory: %w", err)})
+		return
+	}
+	w.forgetRemoved(entries)
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if !audioExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+
+		path := filepath.Join(w.dir, entry.Name())
+		if !w.isStable(path, entry) {
+			continue
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			continue // The file may have been removed between listing and reading
+		}
+
+		w.mu.Lock()
+		duplicate := w.ledger.has(hash) || w.inFlight[hash]
+		if duplicate {
+			w.handled[path] = true
+		}
+		w.mu.Unlock()
+		if duplicate {
+			continue
+		}
+
+		// Stop scanning when the workers are saturated; remaining files are picked up next scan
+		select {
+		case w.jobs <- job{source: path, hash: hash}:
+			w.mu.Lock()
+			w.inFlight[hash] = true
+			w.handled[path] = true
+			w.mu.Unlock()
+			atomic.AddInt32(&w.queued, 1)
+			w.emit(Event{Kind: Queued, Source: path})
+		case <-ctx.Done():
+			return
+		default:
+			return
+		}
+	}
+}
+
+// isStable reports whether a file has stopped changing since the previous scan
+func (w *Watcher) isStable(path string, entry os.DirEntry) bool {
+	info, err := entry.Info()
+	if err != nil {
+		return false
+	}
+	current := fileState{size: info.Size(), modTime: info.ModTime()}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.handled[path] || time.Now().Before(w.retries[path].at) {
+		return false
+	}
+	previous, ok := w.pending[path]
+	w.pending[path] = current
+	if !ok || previous != current || current.size == 0 {
+		return false
+	}
+	delete(w.pending, path)
+	return true
+}
+
+func (w *Watcher) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-w.jobs:
+			atomic.AddInt32(&w.queued, -1)
+			atomic.AddInt32(&w.processing, 1)
+			w.emit(Event{Kind: Started, Source: j.source})
+
+			id, text, err := w.process(j)
+
+			atomic.AddInt32(&w.processing, -1)
+			w.mu.Lock()
+			delete(w.inFlight, j.hash)
+			w.mu.Unlock()
+
+			if err != nil {
+				w.scheduleRetry(j.source)
+				w.emit(Event{Kind: Failed, Source: j.source, ID: id, Err: err})
+			} else {
+				w.mu.Lock()
+				delete(w.retries, j.source)
+				w.mu.Unlock()
+				w.emit(Event{Kind: Completed, Source: j.source, ID: id, Text: text})
+			}
+		}
+	}
+}
+
+// drain takes the jobs the workers didn't get to off the queue once they have
+// stopped, so Status stops counting them and a later scan picks the files up again
+func (w *Watcher) drain() {
+	for {
+		select {
+		case j := <-w.jobs:
+			atomic.AddInt32(&w.queued, -1)
+			w.mu.Lock()
+			delete(w.inFlight, j.hash)
+			delete(w.handled, j.source)
+			w.mu.Unlock()
+		default:
+			return
+		}
+	}
+}
+
+// process imports a file into the recordings directory and transcribes it.
+// The file is only recorded in the ledger once it is transcribed, so failed
+// files are retried.
+func (w *Watcher) process(j job) (string, string, error) {
+	id, audioFile, err := w.importFile(j.source)
+	if err != nil {
+		return "", "", err
+	}
+
+	result, err := w.transcriber.Transcribe(audioFile, w.options)
+	if err != nil {
+		// Remove the converted file and the reserved name; the retry imports it again
+		_ = os.Remove(audioFile)
+		_ = os.Remove(filepath.Join(w.appDataDir, config.RecordingsDir, filepath.Base(audioFile)))
+		return id, "", err
+	}
+
+	w.mu.Lock()
+	err = w.ledger.add(j.hash, entryFor(j.source, id))
+	w.mu.Unlock()
+	if err != nil {
+		return id, "", err
+	}
+	return id, result.Text, nil
+}
+
+// scheduleRetry lets scans pick up a failed file again once its backoff has passed
+func (w *Watcher) scheduleRetry(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	r := w.retries[path]
+	r.attempts++
+	backoff := firstRetry
+	for i := 1; i < r.attempts && backoff < maxRetry; i++ {
+		backoff *= 2
+	}
+	r.at = time.Now().Add(min(backoff, maxRetry))
+	w.retries[path] = r
+	delete(w.handled, path)
+}
+
+// forgetRemoved drops the state kept for files no longer in the folder, so it
+// doesn't grow with every file ever dropped there
+func (w *Watcher) forgetRemoved(entries []os.DirEntry) {
+	present := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		present[filepath.Join(w.dir, entry.Name())] = true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for path := range w.handled {
+		if !present[path] {
+			delete(w.handled, path)
+		}
+	}
+	for path := range w.pending {
+		if !present[path] {
+			delete(w.pending, path)
+		}
+	}
+	for path := range w.retries {
+		if !present[path] {
+			delete(w.retries, path)
+		}
+	}
+}
+
+// importFile converts source into a wav file in the recordings directory, named
+// after the time the source was last modified
+func (w *Watcher) importFile(source string) (string, string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to stat %s: %w", source, err)
+	}
+
+	recordingsDir := filepath.Join(w.appDataDir, config.RecordingsDir)
+	w.mu.Lock()
+	id, audioFile := audio.RecordingPath(recordingsDir, info.ModTime())
+	// Reserve the name before releasing the lock so concurrent imports don't collide
+	if err := os.WriteFile(audioFile, nil, 0644); err != nil {
+		w.mu.Unlock()
+		return "", "", fmt.Errorf("failed to create %s: %w", audioFile, err)
+	}
+	w.mu.Unlock()
+
+	converted := audioFile
+	if w.importDir != "" {
+		converted = filepath.Join(w.importDir, filepath.Base(audioFile))
+	}
+	if err := audio.ConvertToWav(source, converted); err != nil {
+		_ = os.Remove(audioFile)
+		return "", "", err
+	}
+
+	return id, converted, nil
+}
+
+func (w *Watcher) emit(e Event) {
+	select {
+	case w.events <- e:
+	default:
+	}
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
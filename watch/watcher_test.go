@@ -0,0 +1,38 @@
+package watch
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunDropsQueuedJobsOnShutdown(t *testing.T) {
+	w := &Watcher{
+		dir:      t.TempDir(),
+		interval: time.Hour,
+		jobs:     make(chan job, 2),
+		events:   make(chan Event, 64),
+		pending:  map[string]fileState{},
+		handled:  map[string]bool{},
+		inFlight: map[string]bool{},
+		retries:  map[string]retry{},
+	}
+	// Two files queued with no worker left to take them
+	for _, j := range []job{{source: "a.wav", hash: "a"}, {source: "b.wav", hash: "b"}} {
+		w.jobs <- j
+		w.inFlight[j.hash] = true
+		w.handled[j.source] = true
+		w.queued++
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w.Run(ctx)
+
+	if status := w.Status(); status != (Status{}) {
+		t.Errorf("Status() after shutdown = %+v, want nothing queued", status)
+	}
+	if len(w.inFlight) != 0 || len(w.handled) != 0 {
+		t.Errorf("dropped files are still tracked: in flight %v, handled %v", w.inFlight, w.handled)
+	}
+}
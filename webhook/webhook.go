@@ -0,0 +1,334 @@
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lazywhisper/config"
+	"lazywhisper/store"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogFile records every delivery attempt, one JSON object per line
+const LogFile = "webhook_deliveries.jsonl"
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256 of
+// the timestamp, a dot and the body, keyed with the endpoint's secret.
+const (
+	EventHeader     = "X-Lazywhisper-Event"
+	DeliveryHeader  = "X-Lazywhisper-Delivery"
+	TimestampHeader = "X-Lazywhisper-Timestamp"
+	SignatureHeader = "X-Lazywhisper-Signature"
+)
+
+// CompletedEvent is sent when a transcription is saved
+const CompletedEvent = "transcription.completed"
+
+const (
+	firstBackoff = 2 * time.Second
+	maxBackoff   = 5 * time.Minute
+)
+
+// Payload is the JSON body posted to endpoints
+type Payload struct {
+	Event       string    `json:"event"`
+	ID          string    `json:"id"`
+	Title       string    `json:"title,omitempty"`
+	Created     time.Time `json:"created"`
+	Duration    float64   `json:"duration,omitempty"`
+	Language    string    `json:"language,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Translation bool      `json:"translation,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	WordCount   int       `json:"word_count"`
+	Text        string    `json:"text"`
+	Audio       *Audio    `json:"audio,omitempty"`
+}
+
+// Audio is the recording, included for endpoints that ask for it
+type Audio struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	// Data is the file, base64 encoded
+	Data string `json:"data"`
+}
+
+// Attempt is a line of the delivery log
+type Attempt struct {
+	Delivery string    `json:"delivery"`
+	URL      string    `json:"url"`
+	ID       string    `json:"id"`
+	Attempt  int       `json:"attempt"`
+	Time     time.Time `json:"time"`
+	Status   int       `json:"status,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	// Delivered is set on the attempt that succeeded
+	Delivered bool `json:"delivered"`
+	// Final is set when no more attempts will be made
+	Final bool `json:"final"`
+}
+
+type endpoint struct {
+	config.WebhookEndpoint
+	secret []byte
+}
+
+// Sender posts new transcriptions to the configured endpoints in the
+// background, retrying failed deliveries with exponential backoff
+type Sender struct {
+	endpoints   []endpoint
+	maxAttempts int
+	// firstBackoff is the wait before the first retry, doubled for each one after
+	firstBackoff time.Duration
+	store        *store.Store
+	client       *http.Client
+	logPath      string
+
+	logMu   sync.Mutex
+	wg      sync.WaitGroup
+	pending atomic.Int64
+}
+
+// New creates a sender for the endpoints in settings
+func New(settings config.WebhooksSettings, s *store.Store) (*Sender, error) {
+	sender := &Sender{
+		maxAttempts:  settings.MaxAttempts,
+		firstBackoff: firstBackoff,
+		store:        s,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		logPath:      filepath.Join(s.AppDataDir(), LogFile),
+	}
+	for _, e := range settings.Endpoints {
+		if e.URL == "" {
+			return nil, fmt.Errorf("webhook endpoint has no url")
+		}
+		var secret []byte
+		if e.SecretEnv != "" {
+			if secret = []byte(os.Getenv(e.SecretEnv)); len(secret) == 0 {
+				return nil, fmt.Errorf("webhook secret %s is not set", e.SecretEnv)
+			}
+		}
+		sender.endpoints = append(sender.endpoints, endpoint{WebhookEndpoint: e, secret: secret})
+	}
+	return sender, nil
+}
+
+// Send queues a transcription for delivery to every endpoint and returns
+// without waiting for the deliveries
+func (s *Sender) Send(entry store.Entry, text string) error {
+	payload := Payload{
+		Event:       CompletedEvent,
+		ID:          entry.ID,
+		Title:       entry.Title,
+		Created:     entry.Created,
+		Duration:    entry.Duration,
+		Language:    entry.Language,
+		Model:       entry.Model,
+		Translation: entry.Translation,
+		Tags:        entry.Tags,
+		WordCount:   entry.WordCount,
+		Text:        text,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	var withAudio []byte
+	for _, e := range s.endpoints {
+		if !e.IncludeAudio {
+			s.deliver(e, entry.ID, body)
+			continue
+		}
+		if withAudio == nil {
+			if withAudio, err = s.audioBody(payload, entry); err != nil {
+				return err
+			}
+		}
+		s.deliver(e, entry.ID, withAudio)
+	}
+	return nil
+}
+
+// Wait blocks until every queued delivery has succeeded or given up
+func (s *Sender) Wait() {
+	s.wg.Wait()
+}
+
+// Pending returns the number of deliveries still being attempted
+func (s *Sender) Pending() int {
+	return int(s.pending.Load())
+}
+
+// audioBody encodes the payload with the recording attached. Transcriptions
+// without a recording are sent without one.
+func (s *Sender) audioBody(payload Payload, entry store.Entry) ([]byte, error) {
+	if audioFile := s.store.AudioFile(entry); audioFile != "" {
+		data, err := s.store.Cipher().ReadFile(audioFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read recording: %w", err)
+		}
+		if err == nil {
+			contentType := mime.TypeByExtension(filepath.Ext(audioFile))
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			payload.Audio = &Audio{
+				Filename:    filepath.Base(audioFile),
+				ContentType: contentType,
+				Data:        base64.StdEncoding.EncodeToString(data),
+			}
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+	return body, nil
+}
+
+// deliver posts body to e in the background until it is accepted or the attempts run out
+func (s *Sender) deliver(e endpoint, id string, body []byte) {
+	delivery := newDeliveryID()
+	s.wg.Add(1)
+	s.pending.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer s.pending.Add(-1)
+		backoff := s.firstBackoff
+		for attempt := 1; ; attempt++ {
+			status, err := s.post(e, delivery, body)
+			record := Attempt{
+				Delivery:  delivery,
+				URL:       e.URL,
+				ID:        id,
+				Attempt:   attempt,
+				Time:      time.Now(),
+				Status:    status,
+				Delivered: err == nil,
+			}
+			if err != nil {
+				record.Error = err.Error()
+			}
+			retry := err != nil && retryable(status) && attempt < s.maxAttempts
+			record.Final = !retry
+			s.log(record)
+			if !retry {
+				return
+			}
+			time.Sleep(backoff)
+			backoff = min(backoff*2, maxBackoff)
+		}
+	}()
+}
+
+// post sends one delivery attempt and returns the response status
+func (s *Sender) post(e endpoint, delivery string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lazywhisper")
+	req.Header.Set(EventHeader, CompletedEvent)
+	req.Header.Set(DeliveryHeader, delivery)
+	req.Header.Set(TimestampHeader, timestamp)
+	if e.secret != nil {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(e.secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256 receivers can compare the signature header against
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryable reports whether a failed attempt may succeed later: network
+// errors, timeouts, rate limits and server errors
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// log appends an attempt to the delivery log. Logging is best effort; a
+// delivery never fails because it couldn't be logged.
+func (s *Sender) log(attempt Attempt) {
+	data, err := json.Marshal(attempt)
+	if err != nil {
+		return
+	}
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+	file, err := os.OpenFile(s.logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.Write(append(data, '\n'))
+}
+
+// ReadLog returns the last n attempts from the delivery log in appDataDir, oldest first
+func ReadLog(appDataDir string, n int) ([]Attempt, error) {
+	file, err := os.Open(filepath.Join(appDataDir, LogFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", LogFile, err)
+	}
+	defer file.Close()
+
+	var attempts []Attempt
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var attempt Attempt
+		// Skip a line cut short by a crash rather than hide the rest of the log
+		if json.Unmarshal(scanner.Bytes(), &attempt) != nil {
+			continue
+		}
+		attempts = append(attempts, attempt)
+		if len(attempts) > n {
+			attempts = attempts[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", LogFile, err)
+	}
+	return attempts, nil
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
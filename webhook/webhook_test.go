@@ -0,0 +1,287 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"lazywhisper/config"
+	"lazywhisper/store"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// delivery is a request received by a test endpoint
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a test endpoint answering with the given statuses in turn,
+// then 200 once they run out
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	received []delivery
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.received = append(r.received, delivery{header: req.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) deliveries() []delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]delivery(nil), r.received...)
+}
+
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.Open(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newTestSender creates a sender that retries without waiting
+func newTestSender(t *testing.T, s *store.Store, maxAttempts int, endpoints ...config.WebhookEndpoint) *Sender {
+	t.Helper()
+	sender, err := New(config.WebhooksSettings{Endpoints: endpoints, MaxAttempts: maxAttempts}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender.firstBackoff = time.Millisecond
+	return sender
+}
+
+var testEntry = store.Entry{
+	ID:        "2024-01-01-10-00-00",
+	Title:     "Standup",
+	Created:   time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+	Language:  "en",
+	WordCount: 2,
+}
+
+func TestSendSignsDeliveries(t *testing.T) {
+	t.Setenv("TEST_WEBHOOK_SECRET", "s3cret")
+	signed := newReceiver(t)
+	unsigned := newReceiver(t)
+	sender := newTestSender(t, newTestStore(t), 1,
+		config.WebhookEndpoint{URL: signed.URL, SecretEnv: "TEST_WEBHOOK_SECRET"},
+		config.WebhookEndpoint{URL: unsigned.URL},
+	)
+
+	if err := sender.Send(testEntry, "hello world"); err != nil {
+		t.Fatal(err)
+	}
+	sender.Wait()
+
+	got := signed.deliveries()
+	if len(got) != 1 {
+		t.Fatalf("signed endpoint received %d requests, want 1", len(got))
+	}
+	d := got[0]
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(d.header.Get(TimestampHeader) + "."))
+	mac.Write(d.body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); d.header.Get(SignatureHeader) != want {
+		t.Errorf("signature = %q, want %q", d.header.Get(SignatureHeader), want)
+	}
+	if d.header.Get(EventHeader) != CompletedEvent || d.header.Get(DeliveryHeader) == "" {
+		t.Errorf("headers = %v", d.header)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(d.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ID != testEntry.ID || payload.Title != "Standup" || payload.Text != "hello world" || payload.Audio != nil {
+		t.Errorf("payload = %+v", payload)
+	}
+
+	got = unsigned.deliveries()
+	if len(got) != 1 {
+		t.Fatalf("endpoint without a secret received %d requests, want 1", len(got))
+	}
+	if signature := got[0].header.Get(SignatureHeader); signature != "" {
+		t.Errorf("endpoint without a secret got signature %q", signature)
+	}
+}
+
+func TestSendIncludesAudio(t *testing.T) {
+	s := newTestStore(t)
+	recording := filepath.Join(s.AppDataDir(), config.RecordingsDir, testEntry.ID+".wav")
+	if err := os.WriteFile(recording, []byte("RIFF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry := testEntry
+	entry.AudioPath = filepath.Join(config.RecordingsDir, testEntry.ID+".wav")
+
+	r := newReceiver(t)
+	sender := newTestSender(t, s, 1, config.WebhookEndpoint{URL: r.URL, IncludeAudio: true})
+	if err := sender.Send(entry, "text"); err != nil {
+		t.Fatal(err)
+	}
+	sender.Wait()
+
+	var payload Payload
+	if err := json.Unmarshal(r.deliveries()[0].body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Audio == nil || payload.Audio.Filename != testEntry.ID+".wav" || payload.Audio.Data != base64.StdEncoding.EncodeToString([]byte("RIFF")) {
+		t.Errorf("audio = %+v", payload.Audio)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		statuses      []int
+		wantStatuses  []int
+		wantDelivered bool
+	}{
+		{"delivered first time", nil, []int{200}, true},
+		{"server error is retried", []int{500, 502}, []int{500, 502, 200}, true},
+		{"rate limit is retried", []int{429}, []int{429, 200}, true},
+		{"timeout is retried", []int{408}, []int{408, 200}, true},
+		{"client error isn't retried", []int{400}, []int{400}, false},
+		{"not found isn't retried", []int{404}, []int{404}, false},
+		{"gives up after max attempts", []int{503, 503, 503, 503}, []int{503, 503, 503}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			r := newReceiver(t, tt.statuses...)
+			sender := newTestSender(t, s, 3, config.WebhookEndpoint{URL: r.URL})
+			if err := sender.Send(testEntry, "text"); err != nil {
+				t.Fatal(err)
+			}
+			sender.Wait()
+			if sender.Pending() != 0 {
+				t.Errorf("Pending() = %d after Wait, want 0", sender.Pending())
+			}
+
+			received := r.deliveries()
+			if len(received) != len(tt.wantStatuses) {
+				t.Fatalf("endpoint received %d requests, want %d", len(received), len(tt.wantStatuses))
+			}
+			// Retries keep the delivery id so receivers can drop duplicates
+			for _, d := range received {
+				if d.header.Get(DeliveryHeader) != received[0].header.Get(DeliveryHeader) {
+					t.Errorf("delivery ids differ between attempts")
+				}
+			}
+
+			attempts, err := ReadLog(s.AppDataDir(), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(attempts) != len(tt.wantStatuses) {
+				t.Fatalf("log has %d attempts, want %d", len(attempts), len(tt.wantStatuses))
+			}
+			for i, a := range attempts {
+				last := i == len(attempts)-1
+				if a.Attempt != i+1 || a.Status != tt.wantStatuses[i] || a.URL != r.URL || a.ID != testEntry.ID {
+					t.Errorf("attempt %d = %+v", i+1, a)
+				}
+				if a.Delivery != received[0].header.Get(DeliveryHeader) {
+					t.Errorf("attempt %d delivery = %q, want the id sent in the header", i+1, a.Delivery)
+				}
+				if a.Final != last {
+					t.Errorf("attempt %d final = %v, want %v", i+1, a.Final, last)
+				}
+				delivered := last && tt.wantDelivered
+				if a.Delivered != delivered || (a.Error == "") != delivered {
+					t.Errorf("attempt %d delivered = %v, error = %q, want delivered %v", i+1, a.Delivered, a.Error, delivered)
+				}
+			}
+		})
+	}
+}
+
+func TestSendRetriesNetworkErrors(t *testing.T) {
+	s := newTestStore(t)
+	r := newReceiver(t)
+	r.Close()
+
+	sender := newTestSender(t, s, 2, config.WebhookEndpoint{URL: r.URL})
+	if err := sender.Send(testEntry, "text"); err != nil {
+		t.Fatal(err)
+	}
+	sender.Wait()
+
+	attempts, err := ReadLog(s.AppDataDir(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("log has %d attempts, want 2", len(attempts))
+	}
+	for _, a := range attempts {
+		if a.Status != 0 || a.Error == "" || a.Delivered {
+			t.Errorf("attempt = %+v, want a network error", a)
+		}
+	}
+	if !attempts[1].Final {
+		t.Error("last attempt isn't final")
+	}
+}
+
+func TestReadLog(t *testing.T) {
+	dir := t.TempDir()
+	if attempts, err := ReadLog(dir, 5); err != nil || attempts != nil {
+		t.Errorf("ReadLog() without a log = %v, %v, want nothing", attempts, err)
+	}
+
+	var lines []byte
+	for i := 1; i <= 4; i++ {
+		line, _ := json.Marshal(Attempt{Delivery: "d", Attempt: i})
+		lines = append(append(lines, line...), '\n')
+	}
+	// A line cut short by a crash
+	lines = append(lines, `{"delivery":"d","att`...)
+	if err := os.WriteFile(filepath.Join(dir, LogFile), lines, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	attempts, err := ReadLog(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 || attempts[0].Attempt != 3 || attempts[1].Attempt != 4 {
+		t.Errorf("ReadLog() = %+v, want the last two attempts", attempts)
+	}
+}
+
+func TestNewRejectsMissingSecret(t *testing.T) {
+	t.Setenv("TEST_WEBHOOK_SECRET", "")
+	_, err := New(config.WebhooksSettings{
+		Endpoints:   []config.WebhookEndpoint{{URL: "http://localhost", SecretEnv: "TEST_WEBHOOK_SECRET"}},
+		MaxAttempts: 1,
+	}, newTestStore(t))
+	if err == nil {
+		t.Error("New() with an unset secret succeeded")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"lazywhisper/config"
+	"lazywhisper/webhook"
+	"os"
+)
+
+// runWebhooks prints the most recent webhook delivery attempts
+func runWebhooks(args []string) int {
+	fs := flag.NewFlagSet("webhooks", flag.ContinueOnError)
+	last := fs.Int("last", 20, "number of attempts to show")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	appDataDir, err := config.GetAppDataDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	attempts, err := webhook.ReadLog(appDataDir, max(*last, 1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(attempts) == 0 {
+		fmt.Println("No webhook deliveries yet")
+		return 0
+	}
+
+	for _, attempt := range attempts {
+		fmt.Printf("%s  %s  %s  #%d  %s\n",
+			attempt.Time.Format("2006-01-02 15:04:05"),
+			attempt.ID,
+			attempt.URL,
+			attempt.Attempt,
+			deliveryOutcome(attempt),
+		)
+	}
+	return 0
+}
+
+// deliveryOutcome describes a delivery attempt
+func deliveryOutcome(attempt webhook.Attempt) string {
+	switch {
+	case attempt.Delivered:
+		return fmt.Sprintf("delivered (%d)", attempt.Status)
+	case attempt.Final:
+		return "failed, gave up: " + attempt.Error
+	default:
+		return "failed, will retry: " + attempt.Error
+	}
+}